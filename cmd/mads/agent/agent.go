@@ -9,8 +9,11 @@ import (
 	"path/filepath"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/arnarg/mads/cmd/mads/flags"
 	"github.com/arnarg/mads/pkg/orchestrator"
+	"github.com/arnarg/mads/pkg/template"
 	"github.com/arnarg/mads/pkg/watcher"
 	"github.com/urfave/cli/v2"
 )
@@ -26,6 +29,35 @@ var Command = &cli.Command{
 			Aliases: []string{"w"},
 			EnvVars: []string{"MADS_WATCH_DIR"},
 		},
+		&cli.StringSliceFlag{
+			Name:    "extensions",
+			Usage:   "File extensions of pod definition files in watch-dir, other files are ignored",
+			EnvVars: []string{"MADS_EXTENSIONS"},
+			Value:   cli.NewStringSlice(".yaml", ".yml", ".json"),
+		},
+		&cli.DurationFlag{
+			Name:    "debounce",
+			Usage:   "How long a pod definition file has to be left alone after a change before it's read",
+			EnvVars: []string{"MADS_DEBOUNCE"},
+			Value:   500 * time.Millisecond,
+		},
+		&cli.DurationFlag{
+			Name:    "reconcile-interval",
+			Usage:   "How often to repair pods that have drifted from their definition, 0 disables it",
+			EnvVars: []string{"MADS_RECONCILE_INTERVAL"},
+			Value:   time.Minute,
+		},
+		&cli.BoolFlag{
+			Name:    "prune",
+			Usage:   "Delete pods and consul services managed by mads that have no definition in watch-dir, when false they are only reported",
+			EnvVars: []string{"MADS_PRUNE"},
+			Value:   true,
+		},
+		&cli.BoolFlag{
+			Name:    "periodic-prune",
+			Usage:   "Also collect pods and consul services that have no definition in watch-dir on every reconciliation, not only when the agent starts",
+			EnvVars: []string{"MADS_PERIODIC_PRUNE"},
+		},
 	},
 	Before: before,
 	Action: run,
@@ -67,20 +99,51 @@ func run(cCtx *cli.Context) error {
 	// Get watch-dir path
 	watchDir := cCtx.String("watch-dir")
 
+	// Get pod definition file extensions
+	extensions := cCtx.StringSlice("extensions")
+
+	// Get debounce period for file events
+	debounce := cCtx.Duration("debounce")
+
 	// Get envoy image
 	envoyImage := cCtx.String("envoy-image")
 
+	// Get consul client configuration
+	consulCfg := flags.ConsulConfig(cCtx)
+
+	// Get reconcile interval
+	reconcileInterval := cCtx.Duration("reconcile-interval")
+
+	// Get prune setting
+	prune := cCtx.Bool("prune")
+	periodicPrune := cCtx.Bool("periodic-prune")
+
 	// Create orchestrator instance
 	orch, err := orchestrator.NewOrchestrator(&orchestrator.Config{
 		PodmanSocketPath: socket,
 		EnvoyImage:       envoyImage,
+		ConsulConfig:     consulCfg,
 	})
 	if err != nil {
 		return err
 	}
 
+	// Create template renderer if templating is enabled
+	var renderer *template.Renderer
+	if cCtx.Bool("template") {
+		renderer, err = template.NewRenderer(&template.Config{ConsulConfig: consulCfg})
+		if err != nil {
+			return err
+		}
+	}
+
 	// Create a file watcher
-	w := watcher.NewFileWatcher(watchDir)
+	w := watcher.NewFileWatcher(&watcher.Config{
+		Path:       watchDir,
+		Extensions: extensions,
+		Debounce:   debounce,
+		Renderer:   renderer,
+	})
 
 	// Create an app context
 	appCtx, cancel := context.WithCancel(context.Background())
@@ -100,13 +163,32 @@ func run(cCtx *cli.Context) error {
 		}
 	}()
 
+	// Setup periodic reconciliation
+	var reconcileCh <-chan time.Time
+	if reconcileInterval > 0 {
+		ticker := time.NewTicker(reconcileInterval)
+		defer ticker.Stop()
+
+		reconcileCh = ticker.C
+	}
+
 	// Catch sigint
 	intChan := make(chan os.Signal, 10)
 	signal.Notify(intChan, os.Interrupt, syscall.SIGTERM) // Stop running
 
 	// Wait for events
+	synced := false
+	syncedCh := w.Synced()
 	for {
 		select {
+		// All files have been read, clean up anything left behind while the agent was down
+		case <-syncedCh:
+			// A closed channel is always ready so we stop selecting on it
+			syncedCh = nil
+			synced = true
+
+			collectGarbage(appCtx, orch, w, prune)
+
 		// File event
 		case ev, ok := <-w.PodFileEvents():
 			if !ok {
@@ -121,8 +203,15 @@ func run(cCtx *cli.Context) error {
 				err := orch.Apply(appCtx, ev.Pod)
 				if err != nil {
 					log.Printf("could not apply pod '%s': %s", ev.Pod.Name, err)
+
+					// Saving the file again should retry even if it's unchanged
+					w.Forget(ev.Pod.Name)
 				}
 
+			// File is invalid, pods from the last valid version are left running
+			case watcher.TypeInvalid:
+				log.Printf("ignoring invalid pod file '%s': %s", ev.Path, ev.Err)
+
 			// Pod should be deleted
 			case watcher.TypeDelete:
 				log.Printf("deleting pod '%s'", ev.Name)
@@ -133,6 +222,17 @@ func run(cCtx *cli.Context) error {
 				}
 			}
 
+		// Repair drift
+		case <-reconcileCh:
+			if synced {
+				reconcile(appCtx, orch, w)
+
+				// Pods applied outside of the agent are only removed when asked to
+				if periodicPrune {
+					collectGarbage(appCtx, orch, w, prune)
+				}
+			}
+
 		// Get error from watcher
 		case err := <-errCh:
 			return err
@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"context"
+	"log"
+
+	"github.com/arnarg/mads/pkg/orchestrator"
+	"github.com/arnarg/mads/pkg/watcher"
+)
+
+// collectGarbage removes pods and consul services managed by mads that have no
+// definition in the watch directory. If prune is false they are only reported.
+// Nothing is collected while any file is invalid, as the pods of its last valid
+// version should be left running and we don't know which ones they are.
+func collectGarbage(ctx context.Context, orch *orchestrator.Orchestrator, w *watcher.FileWatcher, prune bool) {
+	if invalid := w.InvalidFiles(); len(invalid) > 0 {
+		for p := range invalid {
+			log.Printf("gc: skipping garbage collection as pod file '%s' is invalid", p)
+		}
+		return
+	}
+
+	// Get desired pods
+	desired := w.Pods()
+
+	// Find orphaned pods
+	pods, err := orch.ManagedPods(ctx)
+	if err != nil {
+		log.Printf("gc: could not list pods: %s", err)
+		return
+	}
+
+	for _, name := range pods {
+		if _, ok := desired[name]; ok {
+			continue
+		}
+
+		if !prune {
+			log.Printf("gc: pod '%s' has no definition, not deleting it as pruning is disabled", name)
+			continue
+		}
+
+		log.Printf("gc: deleting pod '%s' which has no definition", name)
+
+		err := orch.Delete(ctx, name)
+		if err != nil {
+			log.Printf("gc: could not delete pod '%s': %s", name, err)
+		}
+	}
+
+	// Find orphaned volumes that should be deleted along with their pod.
+	// Volumes of pods deleted above are normally already gone but the pod could
+	// have been deleted while the agent wasn't running.
+	volumes, err := orch.ManagedVolumes(ctx)
+	if err != nil {
+		log.Printf("gc: could not list volumes: %s", err)
+		return
+	}
+
+	for name, podName := range volumes {
+		if _, ok := desired[podName]; ok {
+			continue
+		}
+
+		if !prune {
+			log.Printf("gc: volume '%s' of pod '%s' has no definition, not deleting it as pruning is disabled", name, podName)
+			continue
+		}
+
+		log.Printf("gc: deleting volume '%s' of pod '%s' which has no definition", name, podName)
+
+		err := orch.RemoveVolume(ctx, name)
+		if err != nil {
+			log.Printf("gc: could not delete volume '%s': %s", name, err)
+		}
+	}
+
+	// Find orphaned consul services.
+	// Services of pods deleted above are already gone but the pod could have
+	// been removed from podman without its services being deregistered.
+	services, err := orch.ManagedServices(ctx)
+	if err != nil {
+		log.Printf("gc: could not list consul services: %s", err)
+		return
+	}
+
+	for id, podName := range services {
+		if _, ok := desired[podName]; ok {
+			continue
+		}
+
+		if !prune {
+			log.Printf("gc: consul service '%s' of pod '%s' has no definition, not deregistering it as pruning is disabled", id, podName)
+			continue
+		}
+
+		log.Printf("gc: deregistering consul service '%s' of pod '%s' which has no definition", id, podName)
+
+		err := orch.DeregisterService(ctx, id)
+		if err != nil {
+			log.Printf("gc: could not deregister consul service '%s': %s", id, err)
+		}
+	}
+}
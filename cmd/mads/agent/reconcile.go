@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"context"
+	"log"
+
+	"github.com/arnarg/mads/pkg/orchestrator"
+	"github.com/arnarg/mads/pkg/watcher"
+)
+
+// reconcile compares pods defined in the watch directory with the pods in podman
+// and services in consul and repairs any differences.
+// It must only be called after the watcher has read all files once.
+func reconcile(ctx context.Context, orch *orchestrator.Orchestrator, w *watcher.FileWatcher) {
+	// Get desired pods
+	desired := w.Pods()
+
+	// Repair pods that have drifted from their definition
+	for name, pod := range desired {
+		drift, err := orch.Drift(ctx, pod)
+		if err != nil {
+			log.Printf("reconcile: could not check pod '%s': %s", name, err)
+			continue
+		}
+
+		if !drift.Drifted() {
+			continue
+		}
+
+		for _, reason := range drift.Reasons {
+			log.Printf("reconcile: pod '%s' has drifted: %s", name, reason)
+		}
+
+		// Recreate pod if it can't be repaired in place
+		if drift.Recreate {
+			log.Printf("reconcile: recreating pod '%s'", name)
+
+			err := orch.Recreate(ctx, pod)
+			if err != nil {
+				log.Printf("reconcile: could not recreate pod '%s': %s", name, err)
+			}
+			continue
+		}
+
+		log.Printf("reconcile: applying pod '%s'", name)
+
+		err = orch.Apply(ctx, pod)
+		if err != nil {
+			log.Printf("reconcile: could not apply pod '%s': %s", name, err)
+		}
+	}
+}
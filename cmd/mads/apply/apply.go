@@ -3,13 +3,11 @@ package apply
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
 
-	"github.com/arnarg/mads/pkg/entities"
+	"github.com/arnarg/mads/cmd/mads/flags"
+	"github.com/arnarg/mads/cmd/mads/plan"
 	"github.com/arnarg/mads/pkg/orchestrator"
 	"github.com/urfave/cli/v2"
-	"gopkg.in/yaml.v3"
 )
 
 var Command = &cli.Command{
@@ -18,49 +16,41 @@ var Command = &cli.Command{
 	Usage:       "Apply a single pod definition file",
 	Description: "Reads provided file and applies it to podman",
 	ArgsUsage:   "FILE [FILE...]",
-	Action:      run,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Only show what would be changed",
+		},
+	},
+	Action: run,
 }
 
 func run(cCtx *cli.Context) error {
+	// Only print a plan in dry-run mode
+	if cCtx.Bool("dry-run") {
+		return plan.Run(cCtx)
+	}
+
 	// Get podman socket path
 	socket := cCtx.String("socket")
 
+	// Get consul client configuration
+	consulCfg := flags.ConsulConfig(cCtx)
+
 	// Get envoy image
 	envoyImage := cCtx.String("envoy-image")
 
-	// Get list of pod definition paths
-	paths := cCtx.Args().Slice()
-
-	// Parse all pod definition files
-	pods := []*entities.Pod{}
-	for _, fpath := range paths {
-		// Resolve path
-		rpath, err := filepath.Abs(fpath)
-		if err != nil {
-			return fmt.Errorf("could not resolve path '%s': %s", fpath, err)
-		}
-
-		// Read file
-		def, err := ioutil.ReadFile(rpath)
-		if err != nil {
-			return fmt.Errorf("could not read file '%s': %s", fpath, err)
-		}
-
-		// Parse file contents
-		pod := &entities.Pod{}
-		err = yaml.Unmarshal(def, pod)
-		if err != nil {
-			return fmt.Errorf("could not parse yaml file '%s': %s", fpath, err)
-		}
-
-		// Add to slice of pods
-		pods = append(pods, pod)
+	// Read all pod definition files
+	pods, err := flags.ReadPodFiles(cCtx, consulCfg)
+	if err != nil {
+		return err
 	}
 
 	// Create an orchestrator instance
 	orch, err := orchestrator.NewOrchestrator(&orchestrator.Config{
 		PodmanSocketPath: socket,
 		EnvoyImage:       envoyImage,
+		ConsulConfig:     consulCfg,
 	})
 	if err != nil {
 		return err
@@ -3,6 +3,7 @@ package delete
 import (
 	"context"
 
+	"github.com/arnarg/mads/cmd/mads/flags"
 	"github.com/arnarg/mads/pkg/orchestrator"
 	"github.com/urfave/cli/v2"
 )
@@ -20,12 +21,16 @@ func run(cCtx *cli.Context) error {
 	// Get podman socket path
 	socket := cCtx.String("socket")
 
+	// Get consul client configuration
+	consulCfg := flags.ConsulConfig(cCtx)
+
 	// Get list of pod names to delete
 	podNames := cCtx.Args().Slice()
 
 	// Create orchestrator instance
 	orch, err := orchestrator.NewOrchestrator(&orchestrator.Config{
 		PodmanSocketPath: socket,
+		ConsulConfig:     consulCfg,
 	})
 	if err != nil {
 		return err
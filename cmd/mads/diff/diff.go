@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arnarg/mads/cmd/mads/flags"
+	"github.com/arnarg/mads/pkg/orchestrator"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:        "diff",
+	Usage:       "Compare pod definition files with running pods",
+	Description: "Prints a diff between the last applied configuration of running pods and the provided files",
+	ArgsUsage:   "FILE [FILE...]",
+	Action:      run,
+}
+
+func run(cCtx *cli.Context) error {
+	// Get podman socket path
+	socket := cCtx.String("socket")
+
+	// Get consul client configuration
+	consulCfg := flags.ConsulConfig(cCtx)
+
+	// Get envoy image
+	envoyImage := cCtx.String("envoy-image")
+
+	// Read all pod definition files
+	pods, err := flags.ReadPodFiles(cCtx, consulCfg)
+	if err != nil {
+		return err
+	}
+
+	// Create an orchestrator instance
+	orch, err := orchestrator.NewOrchestrator(&orchestrator.Config{
+		PodmanSocketPath: socket,
+		EnvoyImage:       envoyImage,
+		ConsulConfig:     consulCfg,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Print diff for all pods
+	for _, pod := range pods {
+		plan, err := orch.Plan(context.Background(), pod)
+		if err != nil {
+			return fmt.Errorf("could not compare pod '%s': %s", pod.Name, err)
+		}
+
+		fmt.Print(plan.Diff)
+	}
+
+	return nil
+}
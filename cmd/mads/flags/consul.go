@@ -0,0 +1,89 @@
+package flags
+
+import (
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/urfave/cli/v2"
+)
+
+// Consul are the global flags for connecting to the consul agent.
+// They use the same environment variables as the consul CLI.
+var Consul = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "consul-address",
+		Usage:   "Address of the consul agent, prefix with https:// to use TLS",
+		EnvVars: []string{"CONSUL_HTTP_ADDR"},
+	},
+	&cli.StringFlag{
+		Name:    "consul-token",
+		Usage:   "ACL token used for consul requests and envoy sidecars",
+		EnvVars: []string{"CONSUL_HTTP_TOKEN"},
+	},
+	&cli.StringFlag{
+		Name:    "consul-token-file",
+		Usage:   "File containing the ACL token, takes precedence over consul-token",
+		EnvVars: []string{"CONSUL_HTTP_TOKEN_FILE"},
+	},
+	&cli.StringFlag{
+		Name:    "consul-ca-file",
+		Usage:   "CA certificate used to verify the consul agent, also used by envoy sidecars for gRPC with TLS",
+		EnvVars: []string{"CONSUL_CACERT"},
+	},
+	&cli.StringFlag{
+		Name:    "consul-client-cert",
+		Usage:   "Client certificate for consul agents that verify incoming connections",
+		EnvVars: []string{"CONSUL_CLIENT_CERT"},
+	},
+	&cli.StringFlag{
+		Name:    "consul-client-key",
+		Usage:   "Private key of the client certificate",
+		EnvVars: []string{"CONSUL_CLIENT_KEY"},
+	},
+	&cli.StringFlag{
+		Name:    "consul-tls-server-name",
+		Usage:   "Server name used to verify the certificate of the consul agent",
+		EnvVars: []string{"CONSUL_TLS_SERVER_NAME"},
+	},
+}
+
+// ConsulConfig builds a consul client configuration from the consul flags
+// on top of consul's default configuration.
+func ConsulConfig(cCtx *cli.Context) *api.Config {
+	cfg := api.DefaultConfig()
+
+	// Address, the scheme is set separately
+	if addr := cCtx.String("consul-address"); addr != "" {
+		switch {
+		case strings.HasPrefix(addr, "https://"):
+			cfg.Scheme = "https"
+		case strings.HasPrefix(addr, "http://"):
+			cfg.Scheme = "http"
+		}
+		cfg.Address = strings.TrimPrefix(strings.TrimPrefix(addr, "https://"), "http://")
+	}
+
+	// ACL token
+	if token := cCtx.String("consul-token"); token != "" {
+		cfg.Token = token
+	}
+	if tokenFile := cCtx.String("consul-token-file"); tokenFile != "" {
+		cfg.TokenFile = tokenFile
+	}
+
+	// TLS
+	if caFile := cCtx.String("consul-ca-file"); caFile != "" {
+		cfg.TLSConfig.CAFile = caFile
+	}
+	if certFile := cCtx.String("consul-client-cert"); certFile != "" {
+		cfg.TLSConfig.CertFile = certFile
+	}
+	if keyFile := cCtx.String("consul-client-key"); keyFile != "" {
+		cfg.TLSConfig.KeyFile = keyFile
+	}
+	if serverName := cCtx.String("consul-tls-server-name"); serverName != "" {
+		cfg.TLSConfig.Address = serverName
+	}
+
+	return cfg
+}
@@ -0,0 +1,55 @@
+package flags
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/arnarg/mads/pkg/podfile"
+	"github.com/arnarg/mads/pkg/template"
+	"github.com/hashicorp/consul/api"
+	"github.com/urfave/cli/v2"
+)
+
+// ReadPodFiles reads and parses all pod definition files passed as arguments.
+// The files are rendered as templates first if the template flag is set.
+func ReadPodFiles(cCtx *cli.Context, consulCfg *api.Config) ([]*entities.Pod, error) {
+	// Create template renderer if templating is enabled
+	var renderer *template.Renderer
+	if cCtx.Bool("template") {
+		r, err := template.NewRenderer(&template.Config{ConsulConfig: consulCfg})
+		if err != nil {
+			return nil, err
+		}
+		renderer = r
+	}
+
+	// Get list of pod definition paths
+	paths := cCtx.Args().Slice()
+
+	// Parse all pod definition files
+	pods := []*entities.Pod{}
+	for _, fpath := range paths {
+		// Resolve path
+		rpath, err := filepath.Abs(fpath)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve path '%s': %s", fpath, err)
+		}
+
+		// Read and parse file
+		var fpods []*entities.Pod
+		if renderer != nil {
+			fpods, err = podfile.ReadTemplateFile(rpath, renderer)
+		} else {
+			fpods, err = podfile.ReadFile(rpath)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Add to slice of pods
+		pods = append(pods, fpods...)
+	}
+
+	return pods, nil
+}
@@ -0,0 +1,86 @@
+package get
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/arnarg/mads/cmd/mads/flags"
+	"github.com/arnarg/mads/pkg/orchestrator"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var Command = &cli.Command{
+	Name:        "get",
+	Aliases:     []string{"g"},
+	Usage:       "Print the last applied configuration of a pod",
+	Description: "Reads the configuration stored on a running pod and prints it",
+	ArgsUsage:   "POD [POD...]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "Output format (yaml or json)",
+			Value:   "yaml",
+		},
+	},
+	Action: run,
+}
+
+func run(cCtx *cli.Context) error {
+	// Get podman socket path
+	socket := cCtx.String("socket")
+
+	// Get consul client configuration
+	consulCfg := flags.ConsulConfig(cCtx)
+
+	// Get output format
+	output := cCtx.String("output")
+	if output != "yaml" && output != "json" {
+		return fmt.Errorf("unknown output format '%s'", output)
+	}
+
+	// Get list of pod names
+	podNames := cCtx.Args().Slice()
+
+	// Create orchestrator instance
+	orch, err := orchestrator.NewOrchestrator(&orchestrator.Config{
+		PodmanSocketPath: socket,
+		ConsulConfig:     consulCfg,
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, n := range podNames {
+		// Get last applied configuration
+		snap, err := orch.Get(context.Background(), n)
+		if err != nil {
+			return err
+		}
+
+		// Print it in the requested format
+		switch output {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(snap.Pod)
+		case "yaml":
+			// Separate documents so the output can be applied again
+			if i > 0 {
+				fmt.Println("---")
+			}
+
+			enc := yaml.NewEncoder(os.Stdout)
+			enc.SetIndent(2)
+			err = enc.Encode(snap.Pod)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package list
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/arnarg/mads/cmd/mads/flags"
+	"github.com/arnarg/mads/pkg/orchestrator"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var Command = &cli.Command{
+	Name:        "list",
+	Aliases:     []string{"ls", "status"},
+	Usage:       "List all pods managed by mads",
+	Description: "Lists pods managed by mads along with the state of their containers and consul services",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "Output format (table, json or yaml)",
+			Value:   "table",
+		},
+	},
+	Action: run,
+}
+
+func run(cCtx *cli.Context) error {
+	// Get podman socket path
+	socket := cCtx.String("socket")
+
+	// Get consul client configuration
+	consulCfg := flags.ConsulConfig(cCtx)
+
+	// Get output format
+	output := cCtx.String("output")
+	if output != "table" && output != "yaml" && output != "json" {
+		return fmt.Errorf("unknown output format '%s'", output)
+	}
+
+	// Create orchestrator instance
+	orch, err := orchestrator.NewOrchestrator(&orchestrator.Config{
+		PodmanSocketPath: socket,
+		ConsulConfig:     consulCfg,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Get status of all pods
+	statuses, err := orch.List(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// Print it in the requested format
+	switch output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(statuses)
+	case "yaml":
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		return enc.Encode(statuses)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tINSTANCE\tSTATE\tCONTAINERS\tSERVICES")
+	for _, status := range statuses {
+		ctrs := []string{}
+		for _, c := range status.Containers {
+			ctrs = append(ctrs, fmt.Sprintf("%s=%s", c.Name, c.State))
+		}
+
+		svcs := []string{}
+		for _, s := range status.Services {
+			svcs = append(svcs, fmt.Sprintf("%s=%s", s.ID, s.Health))
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", status.Name, status.Instance, status.State, strings.Join(ctrs, ","), strings.Join(svcs, ","))
+	}
+
+	return tw.Flush()
+}
@@ -7,6 +7,11 @@ import (
 	"github.com/arnarg/mads/cmd/mads/agent"
 	"github.com/arnarg/mads/cmd/mads/apply"
 	"github.com/arnarg/mads/cmd/mads/delete"
+	"github.com/arnarg/mads/cmd/mads/diff"
+	"github.com/arnarg/mads/cmd/mads/flags"
+	"github.com/arnarg/mads/cmd/mads/get"
+	"github.com/arnarg/mads/cmd/mads/list"
+	"github.com/arnarg/mads/cmd/mads/plan"
 	"github.com/urfave/cli/v2"
 )
 
@@ -19,7 +24,7 @@ func main() {
 		Name:        "mads",
 		Version:     version,
 		Description: "Run pods in podman with consul services from a declarative definition.",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:    "socket",
 				Aliases: []string{"s"},
@@ -32,7 +37,12 @@ func main() {
 				EnvVars: []string{"MADS_ENVOY_IMAGE"},
 				Value:   "docker.io/envoyproxy/envoy:v1.22.8",
 			},
-		},
+			&cli.BoolFlag{
+				Name:    "template",
+				Usage:   "Render pod definition files as templates with data from consul before parsing them",
+				EnvVars: []string{"MADS_TEMPLATE"},
+			},
+		}, flags.Consul...),
 		Before: func(cCtx *cli.Context) error {
 			// Expand env variable in socket flag
 			rsocket := os.ExpandEnv(cCtx.String("socket"))
@@ -43,6 +53,10 @@ func main() {
 		},
 		Commands: cli.Commands{
 			apply.Command,
+			plan.Command,
+			diff.Command,
+			get.Command,
+			list.Command,
 			delete.Command,
 			agent.Command,
 		},
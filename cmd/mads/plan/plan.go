@@ -0,0 +1,106 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/arnarg/mads/cmd/mads/flags"
+	"github.com/arnarg/mads/pkg/orchestrator"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:        "plan",
+	Aliases:     []string{"p"},
+	Usage:       "Show what applying pod definition files would change",
+	Description: "Reads provided files and prints what would be changed in podman and consul without changing anything",
+	ArgsUsage:   "FILE [FILE...]",
+	Action:      Run,
+}
+
+// Run prints a plan for every pod definition file passed as an argument.
+func Run(cCtx *cli.Context) error {
+	// Get podman socket path
+	socket := cCtx.String("socket")
+
+	// Get envoy image
+	envoyImage := cCtx.String("envoy-image")
+
+	// Get consul client configuration
+	consulCfg := flags.ConsulConfig(cCtx)
+
+	// Read all pod definition files
+	pods, err := flags.ReadPodFiles(cCtx, consulCfg)
+	if err != nil {
+		return err
+	}
+
+	// Create an orchestrator instance
+	orch, err := orchestrator.NewOrchestrator(&orchestrator.Config{
+		PodmanSocketPath: socket,
+		EnvoyImage:       envoyImage,
+		ConsulConfig:     consulCfg,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Plan all pods
+	for _, pod := range pods {
+		plan, err := orch.Plan(context.Background(), pod)
+		if err != nil {
+			return fmt.Errorf("could not plan pod '%s': %s", pod.Name, err)
+		}
+
+		printPlan(os.Stdout, plan)
+	}
+
+	return nil
+}
+
+func printPlan(w io.Writer, plan *orchestrator.Plan) {
+	switch plan.Action {
+	case orchestrator.PlanActionCreate:
+		fmt.Fprintf(w, "pod '%s' will be created\n", plan.Pod)
+	case orchestrator.PlanActionReplace:
+		fmt.Fprintf(w, "pod '%s' will be replaced (%s)\n", plan.Pod, plan.Strategy)
+	default:
+		fmt.Fprintf(w, "pod '%s' is unchanged\n", plan.Pod)
+	}
+
+	// Print consul services
+	if len(plan.Services) > 0 {
+		fmt.Fprintln(w, "  services:")
+		for _, svc := range plan.Services {
+			if svc.Name != "" {
+				fmt.Fprintf(w, "    %s %s (%s)\n", svc.Action, svc.ID, svc.Name)
+			} else {
+				fmt.Fprintf(w, "    %s %s\n", svc.Action, svc.ID)
+			}
+		}
+	}
+
+	// Print sidecar containers
+	if len(plan.Sidecars) > 0 {
+		fmt.Fprintln(w, "  sidecars:")
+		for _, sc := range plan.Sidecars {
+			fmt.Fprintf(w, "    %s %s (%s)\n", sc.Action, sc.Name, sc.Service)
+		}
+	}
+
+	// Print configuration diff
+	if plan.Diff != "" {
+		fmt.Fprintln(w, "  diff:")
+		fmt.Fprint(w, indent(plan.Diff, "    "))
+	}
+
+	fmt.Fprintln(w)
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	return prefix + strings.Join(lines, "\n"+prefix) + "\n"
+}
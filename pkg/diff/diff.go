@@ -0,0 +1,152 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Number of unchanged lines to show around changes.
+const contextLines = 3
+
+type op struct {
+	kind byte
+	line string
+}
+
+// Unified returns a unified diff between a and b, or an empty string if they are equal.
+func Unified(aName, bName, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	ops := lineOps(splitLines(a), splitLines(b))
+
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", aName, bName)
+
+	// Walk through operations and group changes into hunks
+	aLine, bLine := 1, 1
+	for i := 0; i < len(ops); {
+		// Skip forward to the next change
+		if ops[i].kind == ' ' {
+			i++
+			aLine++
+			bLine++
+			continue
+		}
+
+		// Find start of hunk including context
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+		aStart, bStart := aLine-(i-start), bLine-(i-start)
+
+		// Find end of hunk, merging changes that are close together
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*contextLines {
+				break
+			}
+		}
+		stop := end + contextLines + 1
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		// Count lines in hunk
+		aCount, bCount := 0, 0
+		for _, o := range ops[start:stop] {
+			if o.kind != '+' {
+				aCount++
+			}
+			if o.kind != '-' {
+				bCount++
+			}
+		}
+
+		fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, o := range ops[start:stop] {
+			fmt.Fprintf(buf, "%c%s\n", o.kind, o.line)
+		}
+
+		// Continue after the hunk
+		for _, o := range ops[i:stop] {
+			if o.kind != '+' {
+				aLine++
+			}
+			if o.kind != '-' {
+				bLine++
+			}
+		}
+		i = stop
+	}
+
+	return buf.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lineOps computes the operations needed to turn a into b using
+// the longest common subsequence of lines.
+func lineOps(a, b []string) []op {
+	// Build LCS length table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Walk the table to get operations
+	ops := []op{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{'+', b[j]})
+	}
+
+	return ops
+}
@@ -0,0 +1,90 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines "1" to "n", replacing the ones in repl.
+func numbered(n int, repl map[int]string) string {
+	buf := &strings.Builder{}
+	for i := 1; i <= n; i++ {
+		if s, ok := repl[i]; ok {
+			buf.WriteString(s + "\n")
+			continue
+		}
+		fmt.Fprintf(buf, "%d\n", i)
+	}
+	return buf.String()
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "empty",
+			a:    "",
+			b:    "",
+			want: "",
+		},
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "pure insert",
+			a:    "",
+			b:    "a\nb\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "pure delete",
+			a:    "a\nb\n",
+			b:    "",
+			want: "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "insert in the middle",
+			a:    "a\nc\n",
+			b:    "a\nb\nc\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n+b\n c\n",
+		},
+		{
+			name: "context trimming",
+			a:    numbered(10, nil),
+			b:    numbered(10, map[int]string{5: "five"}),
+			want: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			a:    numbered(20, nil),
+			b:    numbered(20, map[int]string{2: "two", 18: "eighteen"}),
+			want: "--- old\n+++ new\n" +
+				"@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -15,6 +15,6 @@\n 15\n 16\n 17\n-18\n+eighteen\n 19\n 20\n",
+		},
+		{
+			name: "merged hunks",
+			a:    numbered(12, nil),
+			b:    numbered(12, map[int]string{3: "three", 8: "eight"}),
+			want: "--- old\n+++ new\n" +
+				"@@ -1,11 +1,11 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n 7\n-8\n+eight\n 9\n 10\n 11\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Unified("old", "new", tt.a, tt.b)
+			if got != tt.want {
+				t.Errorf("unexpected diff\ngot:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
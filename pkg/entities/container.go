@@ -1,17 +1,31 @@
 package entities
 
-import "github.com/creasty/defaults"
+import (
+	"github.com/creasty/defaults"
+)
 
 type Container struct {
 	Name            string                 `yaml:"name" json:"name"`
 	Image           string                 `yaml:"image" json:"image"`
-	ImagePullPolicy string                 `default:"always" yaml:"imagePullPolicy" json:"imagePullPolicy,omitempty"`
-	RestartPolicy   string                 `default:"always" yaml:"restartPolicy" json:"restartPolicy,omitempty"`
-	Args            []string               `yaml:"args" json:"args,omitempty"`
-	Env             map[string]string      `yaml:"env" json:"env,omitempty"`
-	Ports           []ContainerPortMapping `yaml:"ports" json:"ports,omitempty"`
-	Files           []ContainerFile        `yaml:"files" json:"files,omitempty"`
-	Mounts          []ContainerMount       `yaml:"mounts" mounts:"mounts,omitempty"`
+	ImagePullPolicy string                 `default:"always" yaml:"imagePullPolicy,omitempty" json:"imagePullPolicy,omitempty"`
+	Entrypoint      []string               `yaml:"entrypoint,omitempty" json:"entrypoint,omitempty"`
+	RestartPolicy   string                 `default:"always" yaml:"restartPolicy,omitempty" json:"restartPolicy,omitempty"`
+	RestartRetries  uint                   `yaml:"restartRetries,omitempty" json:"restartRetries,omitempty"`
+	Args            []string               `yaml:"args,omitempty" json:"args,omitempty"`
+	Env             map[string]EnvValue    `yaml:"env,omitempty" json:"env,omitempty"`
+	WorkingDir      string                 `yaml:"workingDir,omitempty" json:"workingDir,omitempty"`
+	StopSignal      string                 `yaml:"stopSignal,omitempty" json:"stopSignal,omitempty"`
+	StopTimeout     Duration               `yaml:"stopTimeout,omitempty" json:"stopTimeout,omitempty"`
+	TTY             bool                   `yaml:"tty,omitempty" json:"tty,omitempty"`
+	Stdin           bool                   `yaml:"stdin,omitempty" json:"stdin,omitempty"`
+	Labels          map[string]string      `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Annotations     map[string]string      `yaml:"annotations,omitempty" json:"annotations,omitempty"`
+	Ports           []ContainerPortMapping `yaml:"ports,omitempty" json:"ports,omitempty"`
+	Files           []ContainerFile        `yaml:"files,omitempty" json:"files,omitempty"`
+	Mounts          []ContainerMount       `yaml:"mounts,omitempty" json:"mounts,omitempty"`
+	HealthCheck     *ContainerHealthCheck  `yaml:"healthcheck,omitempty" json:"healthcheck,omitempty"`
+	Resources       *Resources             `yaml:"resources,omitempty" json:"resources,omitempty"`
+	SecurityContext *SecurityContext       `yaml:"securityContext,omitempty" json:"securityContext,omitempty"`
 }
 
 func (c *Container) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -26,16 +40,20 @@ func (c *Container) UnmarshalYAML(unmarshal func(interface{}) error) error {
 }
 
 type ContainerPortMapping struct {
-	HostIP        string `yaml:"hostIP" json:"hostIP,omitempty"`
-	HostPort      uint16 `yaml:"hostPort" json:"hostPort,omitempty"`
-	ContainerPort uint16 `yaml:"containerPort" json:"containerPort,omitempty"`
-	Protocol      string `yaml:"protocol" json:"protocol,omitempty"`
+	HostIP        string `yaml:"hostIP,omitempty" json:"hostIP,omitempty"`
+	HostPort      uint16 `yaml:"hostPort,omitempty" json:"hostPort,omitempty"`
+	ContainerPort uint16 `yaml:"containerPort,omitempty" json:"containerPort,omitempty"`
+	Protocol      string `yaml:"protocol,omitempty" json:"protocol,omitempty"`
 }
 
+// ContainerFile is a file written into a container.
+// Files with ContentFrom are mounted from a podman secret so their content
+// is never stored in the pod configuration.
 type ContainerFile struct {
-	Destination string `yaml:"destination" json:"destination"`
-	Content     string `yaml:"content" json:"content"`
-	Mode        int64  `default:"0644" yaml:"mode" json:"mode,omitempty"`
+	Destination string       `yaml:"destination,omitempty" json:"destination"`
+	Content     string       `yaml:"content,omitempty" json:"content"`
+	ContentFrom *ValueSource `yaml:"contentFrom,omitempty" json:"contentFrom,omitempty"`
+	Mode        int64        `default:"0644" yaml:"mode,omitempty" json:"mode,omitempty"`
 }
 
 func (f *ContainerFile) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -50,10 +68,10 @@ func (f *ContainerFile) UnmarshalYAML(unmarshal func(interface{}) error) error {
 }
 
 type ContainerMount struct {
-	Type        string   `default:"bind" yaml:"type" json:"type"`
-	Source      string   `yaml:"source" json:"source"`
-	Destination string   `yaml:"destination" json:"destination"`
-	Options     []string `yaml:"options" json:"options,omitempty"`
+	Type        string   `default:"bind" yaml:"type,omitempty" json:"type"`
+	Source      string   `yaml:"source,omitempty" json:"source"`
+	Destination string   `yaml:"destination,omitempty" json:"destination"`
+	Options     []string `yaml:"options,omitempty" json:"options,omitempty"`
 }
 
 func (m *ContainerMount) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -66,3 +84,93 @@ func (m *ContainerMount) UnmarshalYAML(unmarshal func(interface{}) error) error
 
 	return nil
 }
+
+// ContainerHealthCheck describes how to tell if a container is healthy.
+// Exactly one of Exec, HTTP or TCP should be set.
+//
+// Exec checks are run inside the container by podman. HTTP and TCP checks are run
+// by the consul agent and also inside the container by podman, which needs curl and
+// nc in the image. For images without them, e.g. distroless ones, ConsulOnly skips
+// the podman healthcheck and the pod is considered ready once it's running.
+// Consul runs HTTP and TCP checks against the host port that the checked port is
+// published on, so it must be published unless the pod uses the host network.
+type ContainerHealthCheck struct {
+	// Exec checks of containers backing a service are also registered in consul as
+	// script checks, which requires enable_local_script_checks in the consul agent
+	Exec        []string                  `yaml:"exec,omitempty" json:"exec,omitempty"`
+	HTTP        *ContainerHealthCheckHTTP `yaml:"http,omitempty" json:"http,omitempty"`
+	TCP         *ContainerHealthCheckTCP  `yaml:"tcp,omitempty" json:"tcp,omitempty"`
+	Interval    Duration                  `default:"30s" yaml:"interval,omitempty" json:"interval,omitempty"`
+	Timeout     Duration                  `default:"5s" yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Retries     int                       `default:"3" yaml:"retries,omitempty" json:"retries,omitempty"`
+	StartPeriod Duration                  `yaml:"startPeriod,omitempty" json:"startPeriod,omitempty"`
+	ConsulOnly  bool                      `yaml:"consulOnly,omitempty" json:"consulOnly,omitempty"`
+}
+
+func (h *ContainerHealthCheck) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	defaults.Set(h)
+
+	type plain ContainerHealthCheck
+	if err := unmarshal((*plain)(h)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type ContainerHealthCheckHTTP struct {
+	Path   string `default:"/" yaml:"path,omitempty" json:"path,omitempty"`
+	Port   uint16 `yaml:"port,omitempty" json:"port"`
+	Scheme string `default:"http" yaml:"scheme,omitempty" json:"scheme,omitempty"`
+}
+
+func (h *ContainerHealthCheckHTTP) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	defaults.Set(h)
+
+	type plain ContainerHealthCheckHTTP
+	if err := unmarshal((*plain)(h)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type ContainerHealthCheckTCP struct {
+	Port uint16 `yaml:"port,omitempty" json:"port"`
+}
+
+// SecurityContext holds the privileges and access control settings of a container.
+type SecurityContext struct {
+	// User and group IDs to run the container process as
+	RunAsUser  *int64 `yaml:"runAsUser,omitempty" json:"runAsUser,omitempty"`
+	RunAsGroup *int64 `yaml:"runAsGroup,omitempty" json:"runAsGroup,omitempty"`
+	// Linux capabilities to add to and drop from the default set, e.g. "NET_ADMIN" or "ALL"
+	Capabilities *Capabilities `yaml:"capabilities,omitempty" json:"capabilities,omitempty"`
+	// Mount the root filesystem of the container as read-only
+	ReadOnlyRootFilesystem bool `yaml:"readOnlyRootFilesystem,omitempty" json:"readOnlyRootFilesystem,omitempty"`
+	Privileged             bool `yaml:"privileged,omitempty" json:"privileged,omitempty"`
+	// Prevent the container process from gaining more privileges, e.g. through setuid binaries
+	NoNewPrivileges bool `yaml:"noNewPrivileges,omitempty" json:"noNewPrivileges,omitempty"`
+	// Path to a seccomp profile on the host, or "unconfined"
+	SeccompProfile string          `yaml:"seccompProfile,omitempty" json:"seccompProfile,omitempty"`
+	SELinuxOptions *SELinuxOptions `yaml:"seLinuxOptions,omitempty" json:"seLinuxOptions,omitempty"`
+	// User namespace mode, e.g. "auto", "keep-id", "host" or "ns:/path/to/ns".
+	// Podman shares the user namespace between all containers in a pod so it's
+	// set on the pod and all containers that set it have to agree.
+	UserNS string `yaml:"userns,omitempty" json:"userns,omitempty"`
+}
+
+type Capabilities struct {
+	Add  []string `yaml:"add,omitempty" json:"add,omitempty"`
+	Drop []string `yaml:"drop,omitempty" json:"drop,omitempty"`
+}
+
+// SELinuxOptions are the SELinux labels applied to the container.
+// Setting Disable turns off SELinux separation for the container.
+type SELinuxOptions struct {
+	User    string `yaml:"user,omitempty" json:"user,omitempty"`
+	Role    string `yaml:"role,omitempty" json:"role,omitempty"`
+	Type    string `yaml:"type,omitempty" json:"type,omitempty"`
+	Level   string `yaml:"level,omitempty" json:"level,omitempty"`
+	Disable bool   `yaml:"disable,omitempty" json:"disable,omitempty"`
+}
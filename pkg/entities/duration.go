@@ -0,0 +1,67 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration that is written as a string like "30s" in both
+// YAML and JSON, so that output of mads can be applied again.
+type Duration time.Duration
+
+// String returns the duration in the form "1m30s".
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration '%s'", node.Line, s)
+	}
+	*d = Duration(dur)
+
+	return nil
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+func (d *Duration) UnmarshalJSON(buf []byte) error {
+	// Snapshots written by older versions of mads have durations in nanoseconds
+	if len(buf) > 0 && buf[0] != '"' {
+		var ns int64
+		if err := json.Unmarshal(buf, &ns); err != nil {
+			return err
+		}
+		*d = Duration(ns)
+
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return err
+	}
+
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration '%s'", s)
+	}
+	*d = Duration(dur)
+
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
@@ -0,0 +1,45 @@
+package entities
+
+import "github.com/creasty/defaults"
+
+const (
+	NetworkModeBridge = "bridge"
+	NetworkModeHost   = "host"
+	NetworkModeNone   = "none"
+)
+
+// PodNetwork is the network configuration of a pod.
+//
+// With "bridge" the pod is attached to the listed podman networks, or podman's
+// default network if none are listed. With "host" the pod uses the network of
+// the host and port mappings are ignored. With "none" the pod only has a loopback interface.
+type PodNetwork struct {
+	Mode     string              `default:"bridge" yaml:"mode,omitempty" json:"mode,omitempty"`
+	Networks []NetworkAttachment `yaml:"networks,omitempty" json:"networks,omitempty"`
+	DNS      *PodDNS             `yaml:"dns,omitempty" json:"dns,omitempty"`
+}
+
+func (n *PodNetwork) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	defaults.Set(n)
+
+	type plain PodNetwork
+	if err := unmarshal((*plain)(n)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// NetworkAttachment attaches a pod to a podman network, which is created if it doesn't exist.
+type NetworkAttachment struct {
+	Name    string   `yaml:"name" json:"name"`
+	IP      string   `yaml:"ip,omitempty" json:"ip,omitempty"`
+	MAC     string   `yaml:"mac,omitempty" json:"mac,omitempty"`
+	Aliases []string `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+}
+
+type PodDNS struct {
+	Servers []string `yaml:"servers,omitempty" json:"servers,omitempty"`
+	Search  []string `yaml:"search,omitempty" json:"search,omitempty"`
+	Options []string `yaml:"options,omitempty" json:"options,omitempty"`
+}
@@ -1,18 +1,43 @@
 package entities
 
 import (
-	"encoding/base64"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 
 	"github.com/creasty/defaults"
 )
 
+const (
+	UpdateStrategyRecreate = "recreate"
+	UpdateStrategyRolling  = "rolling"
+)
+
+// Init containers run to completion one at a time, in the order they are defined,
+// before the other containers in the pod are started. With the init policy "always",
+// which is used when it's empty, they are run every time the pod is started, with
+// "once" only the first time.
+// They don't share a filesystem with the other containers so anything they
+// produce has to be written to a mount.
+const (
+	InitPolicyAlways = "always"
+	InitPolicyOnce   = "once"
+)
+
 type Pod struct {
-	Name       string            `yaml:"name" json:"name"`
-	Hosts      map[string]string `yaml:"hosts"`
-	Labels     map[string]string `yaml:"labels" json:"labels,omitempty"`
-	Containers []Container       `yaml:"containers" json:"containers"`
-	Services   []Service         `yaml:"services" json:"services,omitempty"`
+	Name           string            `yaml:"name" json:"name"`
+	Hostname       string            `yaml:"hostname,omitempty" json:"hostname,omitempty"`
+	Hosts          map[string]string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+	Labels         map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	InitContainers []Container       `yaml:"initContainers,omitempty" json:"initContainers,omitempty"`
+	InitPolicy     string            `yaml:"initPolicy,omitempty" json:"initPolicy,omitempty"`
+	Containers     []Container       `yaml:"containers" json:"containers"`
+	Services       []Service         `yaml:"services,omitempty" json:"services,omitempty"`
+	Volumes        []Volume          `yaml:"volumes,omitempty" json:"volumes,omitempty"`
+	Network        *PodNetwork       `yaml:"network,omitempty" json:"network,omitempty"`
+	UpdateStrategy PodUpdateStrategy `yaml:"updateStrategy,omitempty" json:"updateStrategy"`
+	// Resources are limits for the pod cgroup, shared by all containers in the pod.
+	Resources *Resources `yaml:"resources,omitempty" json:"resources,omitempty"`
 }
 
 func (p *Pod) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -26,11 +51,26 @@ func (p *Pod) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// Hash returns a hash of the pod configuration.
 func (p *Pod) Hash() (string, error) {
 	buf, err := json.Marshal(p)
 	if err != nil {
 		return "", err
 	}
 
-	return base64.RawStdEncoding.EncodeToString(buf), nil
+	sum := sha256.Sum256(buf)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// PodUpdateStrategy decides how a pod is replaced when its configuration changes.
+//
+// With "recreate" the old pod is deleted before the new one is created.
+// With "rolling" the new pod is created next to the old one and the old one
+// is only deleted once all containers in the new pod are running and healthy.
+// Rolling replacements can't be used with fixed host ports as both pods need
+// to be running at the same time.
+type PodUpdateStrategy struct {
+	Type    string   `default:"recreate" yaml:"type,omitempty" json:"type"`
+	Timeout Duration `default:"2m" yaml:"timeout,omitempty" json:"timeout,omitempty"`
 }
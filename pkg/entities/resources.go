@@ -0,0 +1,78 @@
+package entities
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Resources are cgroup resource limits for a container or a pod.
+type Resources struct {
+	Memory            ByteSize `yaml:"memory,omitempty" json:"memory,omitempty"`
+	MemoryReservation ByteSize `yaml:"memoryReservation,omitempty" json:"memoryReservation,omitempty"`
+	// MemorySwap is the limit of memory and swap combined, -1 means unlimited swap.
+	MemorySwap ByteSize `yaml:"memorySwap,omitempty" json:"memorySwap,omitempty"`
+	// CPUs is a shorthand for setting CPUQuota relative to CPUPeriod.
+	CPUs      float64 `yaml:"cpus,omitempty" json:"cpus,omitempty"`
+	CPUQuota  int64   `yaml:"cpuQuota,omitempty" json:"cpuQuota,omitempty"`
+	CPUPeriod uint64  `yaml:"cpuPeriod,omitempty" json:"cpuPeriod,omitempty"`
+	CPUShares uint64  `yaml:"cpuShares,omitempty" json:"cpuShares,omitempty"`
+	Cpuset    string  `yaml:"cpuset,omitempty" json:"cpuset,omitempty"`
+	PidsLimit int64   `yaml:"pidsLimit,omitempty" json:"pidsLimit,omitempty"`
+}
+
+// ByteSize is a size in bytes that can be written in YAML with a unit suffix,
+// e.g. "512m" or "1GiB". Units are powers of 1024, like in podman.
+type ByteSize int64
+
+func (b *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// Plain number of bytes
+	var n int64
+	if err := unmarshal(&n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	size, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = size
+
+	return nil
+}
+
+var byteSizeRegex = regexp.MustCompile(`^(-?[0-9]+)\s*(?:([kmgt])(?:ib?|b)?|b)?$`)
+
+// ParseByteSize parses a size with an optional unit suffix (b, k, m, g or t).
+// The unit can be followed by "b", "i" or "ib", so "512m", "512mb" and "512Mi" are equal.
+func ParseByteSize(s string) (ByteSize, error) {
+	match := byteSizeRegex.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+
+	n, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s': %s", s, err)
+	}
+
+	switch match[2] {
+	case "k":
+		n <<= 10
+	case "m":
+		n <<= 20
+	case "g":
+		n <<= 30
+	case "t":
+		n <<= 40
+	}
+
+	return ByteSize(n), nil
+}
@@ -5,41 +5,80 @@ import "github.com/creasty/defaults"
 // This is mostly a re-creation of a subset of a consul agent service structs
 
 type Service struct {
-	Name    string         `yaml:"name"`
-	Tags    []string       `yaml:"tags"`
-	Port    int            `yaml:"port"`
-	Connect ServiceConnect `yaml:"connect"`
+	Name    string         `yaml:"name" json:"name"`
+	Tags    []string       `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Port    int            `yaml:"port,omitempty" json:"port,omitempty"`
+	Connect ServiceConnect `yaml:"connect,omitempty" json:"connect,omitempty"`
+	// Containers are the names of the containers backing the service, their healthchecks
+	// are registered as checks of the service. Defaults to the containers with a port
+	// mapping for the service port.
+	Containers []string `yaml:"containers,omitempty" json:"containers,omitempty"`
 }
 
 type ServiceConnect struct {
-	Native         bool                   `yaml:"native"`
-	SidecarService *ServiceConnectSidecar `yaml:"sidecarService"`
+	Native         bool                   `yaml:"native,omitempty" json:"native,omitempty"`
+	SidecarService *ServiceConnectSidecar `yaml:"sidecarService,omitempty" json:"sidecarService,omitempty"`
 }
 
 type ServiceConnectSidecar struct {
-	Proxy *ServiceConnectSidecarProxy `yaml:"proxy"`
+	Proxy *ServiceConnectSidecarProxy `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+	// TokenFrom is the ACL token the sidecar proxy uses, instead of the one mads uses.
+	// It's rendered into the envoy bootstrap config so it can't be a podman secret.
+	TokenFrom *ValueSource `yaml:"tokenFrom,omitempty" json:"tokenFrom,omitempty"`
+	// AdminBindAddress is the address the envoy admin API listens on, it's only
+	// reachable from within the pod by default.
+	AdminBindAddress string `yaml:"adminBindAddress,omitempty" json:"adminBindAddress,omitempty"`
+	// EnvoyPrometheusBindAddr is the address, in the form "host:port", where envoy
+	// serves its metrics in the prometheus format on /metrics. The port is published
+	// on the host and registered in the service's consul meta.
+	EnvoyPrometheusBindAddr string `yaml:"envoyPrometheusBindAddr,omitempty" json:"envoyPrometheusBindAddr,omitempty"`
 }
 
 type ServiceConnectSidecarProxy struct {
-	Upstreams []ServiceConnectSidecarProxyUpstream `yaml:"upstreams"`
-	Expose    ServiceConnectSidecarProxyExpose     `yaml:"expose"`
+	Upstreams []ServiceConnectSidecarProxyUpstream `yaml:"upstreams,omitempty" json:"upstreams,omitempty"`
+	Expose    ServiceConnectSidecarProxyExpose     `yaml:"expose,omitempty" json:"expose,omitempty"`
 }
 
+const (
+	UpstreamDestinationService       = "service"
+	UpstreamDestinationPreparedQuery = "prepared_query"
+
+	MeshGatewayModeNone   = "none"
+	MeshGatewayModeLocal  = "local"
+	MeshGatewayModeRemote = "remote"
+)
+
+// ServiceConnectSidecarProxyUpstream mirrors consul's upstream configuration.
+// The upstream listens either on LocalBindAddress and LocalBindPort or on a
+// unix socket at LocalBindSocketPath.
 type ServiceConnectSidecarProxyUpstream struct {
-	LocalBindAddress string `yaml:"localBindAddress"`
-	LocalBindPort    uint16 `yaml:"localBindPort"`
-	DestinationName  string `yaml:"destinationName"`
+	LocalBindAddress     string                 `yaml:"localBindAddress,omitempty" json:"localBindAddress,omitempty"`
+	LocalBindPort        uint16                 `yaml:"localBindPort,omitempty" json:"localBindPort,omitempty"`
+	LocalBindSocketPath  string                 `yaml:"localBindSocketPath,omitempty" json:"localBindSocketPath,omitempty"`
+	LocalBindSocketMode  string                 `yaml:"localBindSocketMode,omitempty" json:"localBindSocketMode,omitempty"`
+	DestinationType      string                 `yaml:"destinationType,omitempty" json:"destinationType,omitempty"`
+	DestinationName      string                 `yaml:"destinationName,omitempty" json:"destinationName,omitempty"`
+	DestinationNamespace string                 `yaml:"destinationNamespace,omitempty" json:"destinationNamespace,omitempty"`
+	DestinationPartition string                 `yaml:"destinationPartition,omitempty" json:"destinationPartition,omitempty"`
+	DestinationPeer      string                 `yaml:"destinationPeer,omitempty" json:"destinationPeer,omitempty"`
+	Datacenter           string                 `yaml:"datacenter,omitempty" json:"datacenter,omitempty"`
+	MeshGateway          *MeshGatewayConfig     `yaml:"meshGateway,omitempty" json:"meshGateway,omitempty"`
+	Config               map[string]interface{} `yaml:"config,omitempty" json:"config,omitempty"`
+}
+
+type MeshGatewayConfig struct {
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
 }
 
 type ServiceConnectSidecarProxyExpose struct {
-	Paths []ServiceConnectSidecarProxyExposePath `yaml:"paths"`
+	Paths []ServiceConnectSidecarProxyExposePath `yaml:"paths,omitempty" json:"paths,omitempty"`
 }
 
 type ServiceConnectSidecarProxyExposePath struct {
-	Path          string `yaml:"path"`
-	LocalPathPort uint16 `yaml:"localPathPort"`
-	ListenerPort  uint16 `yaml:"listenerPort"`
-	Protocol      string `default:"http" yaml:"protocol"`
+	Path          string `yaml:"path,omitempty" json:"path,omitempty"`
+	LocalPathPort uint16 `yaml:"localPathPort,omitempty" json:"localPathPort,omitempty"`
+	ListenerPort  uint16 `yaml:"listenerPort,omitempty" json:"listenerPort,omitempty"`
+	Protocol      string `default:"http" yaml:"protocol,omitempty" json:"protocol,omitempty"`
 }
 
 func (p *ServiceConnectSidecarProxyExposePath) UnmarshalYAML(unmarshal func(interface{}) error) error {
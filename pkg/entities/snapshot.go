@@ -0,0 +1,110 @@
+package entities
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SnapshotVersion is the version of the snapshot format written by EncodeSnapshot.
+const SnapshotVersion = 1
+
+// Snapshot is a decodable copy of an applied pod configuration.
+type Snapshot struct {
+	Version int  `json:"version"`
+	Pod     *Pod `json:"pod"`
+}
+
+// EncodeSnapshot encodes a pod into a versioned snapshot that fits in a label.
+// The format is "v<version>:" followed by base64 encoded, gzipped JSON.
+func EncodeSnapshot(pod *Pod) (string, error) {
+	buf, err := json.Marshal(&Snapshot{Version: SnapshotVersion, Pod: pod})
+	if err != nil {
+		return "", err
+	}
+
+	// Compress the JSON, pod definitions with files can get large
+	zbuf := &bytes.Buffer{}
+	zw := gzip.NewWriter(zbuf)
+	if _, err := zw.Write(buf); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("v%d:%s", SnapshotVersion, base64.RawStdEncoding.EncodeToString(zbuf.Bytes())), nil
+}
+
+// DecodeSnapshot decodes a snapshot created by EncodeSnapshot.
+// Unversioned values written by older versions of mads, plain base64
+// encoded JSON of the pod, are decoded as version 0. Defaults of fields
+// added since are set on those, as if the pod was read from a file.
+func DecodeSnapshot(s string) (*Snapshot, error) {
+	prefix, data, found := strings.Cut(s, ":")
+
+	// No version prefix
+	if !found {
+		buf, err := base64.RawStdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+
+		pod := &Pod{}
+		err = json.Unmarshal(buf, pod)
+		if err != nil {
+			return nil, err
+		}
+
+		// Round trip through YAML to set defaults
+		ybuf, err := yaml.Marshal(pod)
+		if err != nil {
+			return nil, err
+		}
+		pod = &Pod{}
+		err = yaml.Unmarshal(ybuf, pod)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Snapshot{Version: 0, Pod: pod}, nil
+	}
+
+	// Parse version
+	version, err := strconv.Atoi(strings.TrimPrefix(prefix, "v"))
+	if err != nil || !strings.HasPrefix(prefix, "v") {
+		return nil, fmt.Errorf("invalid snapshot version '%s'", prefix)
+	}
+	if version > SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	// Decompress JSON
+	zbuf, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(zbuf))
+	if err != nil {
+		return nil, err
+	}
+	buf, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{}
+	err = json.Unmarshal(buf, snap)
+	if err != nil {
+		return nil, err
+	}
+
+	return snap, nil
+}
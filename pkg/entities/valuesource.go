@@ -0,0 +1,97 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValueSource references a value that is stored outside of the pod definition.
+// Exactly one of the sources should be set.
+type ValueSource struct {
+	// Name of an existing podman secret
+	PodmanSecret string `yaml:"podmanSecret,omitempty" json:"podmanSecret,omitempty"`
+	// Key in consul's KV store
+	ConsulKV string `yaml:"consulKV,omitempty" json:"consulKV,omitempty"`
+	// Path to a file on the host
+	File string `yaml:"file,omitempty" json:"file,omitempty"`
+}
+
+// String returns a unique identifier for the referenced value.
+func (s *ValueSource) String() string {
+	switch {
+	case s.PodmanSecret != "":
+		return "podmanSecret:" + s.PodmanSecret
+	case s.ConsulKV != "":
+		return "consulKV:" + s.ConsulKV
+	default:
+		return "file:" + s.File
+	}
+}
+
+// EnvValue is the value of an environment variable.
+// It's either a plain string or a reference to a value stored elsewhere:
+//
+//	env:
+//	  LOG_LEVEL: debug
+//	  DB_PASSWORD:
+//	    valueFrom:
+//	      consulKV: app/db/password
+type EnvValue struct {
+	Value     string
+	ValueFrom *ValueSource
+}
+
+type envValueFrom struct {
+	ValueFrom *ValueSource `yaml:"valueFrom" json:"valueFrom"`
+}
+
+func (v *EnvValue) UnmarshalYAML(node *yaml.Node) error {
+	// Plain string value
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&v.Value)
+	}
+
+	from := &envValueFrom{}
+	if err := node.Decode(from); err != nil {
+		return err
+	}
+	if from.ValueFrom == nil {
+		return fmt.Errorf("line %d: environment variable needs either a value or valueFrom", node.Line)
+	}
+	v.ValueFrom = from.ValueFrom
+
+	return nil
+}
+
+func (v EnvValue) MarshalYAML() (interface{}, error) {
+	if v.ValueFrom != nil {
+		return &envValueFrom{v.ValueFrom}, nil
+	}
+	return v.Value, nil
+}
+
+func (v *EnvValue) UnmarshalJSON(buf []byte) error {
+	// Plain string value
+	if len(buf) > 0 && buf[0] == '"' {
+		return json.Unmarshal(buf, &v.Value)
+	}
+
+	from := &envValueFrom{}
+	if err := json.Unmarshal(buf, from); err != nil {
+		return err
+	}
+	v.ValueFrom = from.ValueFrom
+
+	return nil
+}
+
+// MarshalJSON encodes plain values as strings so the hash of pods without
+// references is the same as before references were supported.
+func (v EnvValue) MarshalJSON() ([]byte, error) {
+	if v.ValueFrom != nil {
+		return json.Marshal(&envValueFrom{v.ValueFrom})
+	}
+	return json.Marshal(v.Value)
+}
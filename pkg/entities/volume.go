@@ -0,0 +1,33 @@
+package entities
+
+import "github.com/creasty/defaults"
+
+const (
+	VolumeRetentionRetain = "retain"
+	VolumeRetentionDelete = "delete"
+)
+
+// Volume is a named podman volume owned by a pod.
+// Containers mount it with a mount of type "volume" and the volume name as source.
+// Podman volumes can't be changed after they are created so changes to the
+// driver or options only take effect if the volume is deleted and created again.
+type Volume struct {
+	Name    string            `yaml:"name" json:"name"`
+	Driver  string            `default:"local" yaml:"driver,omitempty" json:"driver,omitempty"`
+	Options map[string]string `yaml:"options,omitempty" json:"options,omitempty"`
+	Labels  map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	// RetentionPolicy decides what happens to the volume when the pod is deleted.
+	// With "retain" it's kept and with "delete" it's deleted along with the pod.
+	RetentionPolicy string `default:"retain" yaml:"retentionPolicy,omitempty" json:"retentionPolicy,omitempty"`
+}
+
+func (v *Volume) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	defaults.Set(v)
+
+	type plain Volume
+	if err := unmarshal((*plain)(v)); err != nil {
+		return err
+	}
+
+	return nil
+}
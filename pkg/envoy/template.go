@@ -3,6 +3,7 @@ package envoy
 import (
 	"bytes"
 	"embed"
+	"net"
 	"text/template"
 )
 
@@ -14,11 +15,29 @@ type TemplateParams struct {
 	AdminPort    uint16
 	ServiceName  string
 	ServiceID    string
+	// Consul datacenter, namespace and partition of the sidecar service,
+	// namespace and partition default to "default" when empty
+	Datacenter   string
+	Namespace    string
+	Partition    string
 	AgentAddress string
 	AgentPort    uint16
 	AgentTLS     bool
 	AgentCAPEM   string
 	ConsulToken  string
+	// Address and port of a static listener that serves envoy's metrics
+	// in the prometheus format on /metrics, disabled if the port is 0
+	PrometheusAddress string
+	PrometheusPort    uint16
+}
+
+// AdminClusterAddress returns the address the metrics listener uses to reach
+// the admin API, which can't be connected to on an unspecified address.
+func (p *TemplateParams) AdminClusterAddress() string {
+	if ip := net.ParseIP(p.AdminAddress); ip == nil || ip.IsUnspecified() {
+		return "127.0.0.1"
+	}
+	return p.AdminAddress
 }
 
 func TemplateConfig(params *TemplateParams) (string, error) {
@@ -28,9 +47,19 @@ func TemplateConfig(params *TemplateParams) (string, error) {
 		return "", err
 	}
 
+	// Namespace and partition are empty in consul community edition.
+	// Defaults are set on a copy so the caller's params aren't changed.
+	p := *params
+	if p.Namespace == "" {
+		p.Namespace = "default"
+	}
+	if p.Partition == "" {
+		p.Partition = "default"
+	}
+
 	// Render template into buffer
 	buf := &bytes.Buffer{}
-	err = templ.Execute(buf, params)
+	err = templ.Execute(buf, &p)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,160 @@
+package envoy
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func baseParams() *TemplateParams {
+	return &TemplateParams{
+		AdminAddress: "127.0.0.1",
+		AdminPort:    19000,
+		ServiceName:  "web",
+		ServiceID:    "mads-pod-web-web-sidecar-proxy",
+		Datacenter:   "dc1",
+		AgentAddress: "10.0.0.1",
+		AgentPort:    8502,
+	}
+}
+
+func TestTemplateConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		params func(p *TemplateParams)
+	}{
+		{
+			name:   "defaults",
+			params: func(p *TemplateParams) {},
+		},
+		{
+			name: "datacenter",
+			params: func(p *TemplateParams) {
+				p.Datacenter = "eu-west"
+				p.Namespace = "team-a"
+				p.Partition = "edge"
+			},
+		},
+		{
+			name: "tls",
+			params: func(p *TemplateParams) {
+				p.AgentTLS = true
+			},
+		},
+		{
+			name: "tls_ca",
+			params: func(p *TemplateParams) {
+				p.AgentTLS = true
+				p.AgentCAPEM = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+			},
+		},
+		{
+			name: "token",
+			params: func(p *TemplateParams) {
+				p.ConsulToken = `to"ken\with: quotes`
+			},
+		},
+		{
+			name: "prometheus",
+			params: func(p *TemplateParams) {
+				p.AdminAddress = "0.0.0.0"
+				p.PrometheusAddress = "0.0.0.0"
+				p.PrometheusPort = 9102
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := baseParams()
+			tt.params(params)
+
+			out, err := TemplateConfig(params)
+			if err != nil {
+				t.Fatalf("could not render template: %s", err)
+			}
+
+			// Rendered config must always be valid YAML
+			var v map[string]interface{}
+			if err := yaml.Unmarshal([]byte(out), &v); err != nil {
+				t.Fatalf("rendered config is not valid YAML: %s", err)
+			}
+
+			golden := filepath.Join("testdata", tt.name+".golden.yml")
+			if *update {
+				if err := os.WriteFile(golden, []byte(out), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("could not read golden file: %s", err)
+			}
+			if out != string(want) {
+				t.Errorf("rendered config does not match %s, run with -update to update it\n%s", golden, out)
+			}
+		})
+	}
+}
+
+func TestTemplateConfigValues(t *testing.T) {
+	params := baseParams()
+	params.ConsulToken = `to"ken`
+	params.Datacenter = "eu-west"
+
+	out, err := TemplateConfig(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg struct {
+		Node struct {
+			Metadata map[string]string
+		}
+		StatsConfig struct {
+			StatsTags []struct {
+				TagName    string `yaml:"tag_name"`
+				FixedValue string `yaml:"fixed_value"`
+			} `yaml:"stats_tags"`
+		} `yaml:"stats_config"`
+		DynamicResources struct {
+			AdsConfig struct {
+				GrpcServices struct {
+					InitialMetadata []struct {
+						Key   string
+						Value string
+					} `yaml:"initial_metadata"`
+				} `yaml:"grpc_services"`
+			} `yaml:"ads_config"`
+		} `yaml:"dynamic_resources"`
+	}
+	if err := yaml.Unmarshal([]byte(out), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if ns := cfg.Node.Metadata["namespace"]; ns != "default" {
+		t.Errorf("expected namespace 'default', got '%s'", ns)
+	}
+	if p := cfg.Node.Metadata["partition"]; p != "default" {
+		t.Errorf("expected partition 'default', got '%s'", p)
+	}
+
+	tags := map[string]string{}
+	for _, tag := range cfg.StatsConfig.StatsTags {
+		tags[tag.TagName] = tag.FixedValue
+	}
+	if dc := tags["consul.source.datacenter"]; dc != "eu-west" {
+		t.Errorf("expected datacenter tag 'eu-west', got '%s'", dc)
+	}
+
+	md := cfg.DynamicResources.AdsConfig.GrpcServices.InitialMetadata
+	if len(md) != 1 || md[0].Value != `to"ken` {
+		t.Errorf("expected token to survive quoting, got %v", md)
+	}
+}
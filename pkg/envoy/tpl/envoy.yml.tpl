@@ -8,8 +8,8 @@ node:
   cluster: {{ .ServiceName }}
   id: {{ .ServiceID }}
   metadata:
-    namespace: default
-    partition: default
+    namespace: {{ .Namespace }}
+    partition: {{ .Partition }}
 layered_runtime:
   layers:
   - name: base
@@ -37,10 +37,62 @@ static_resources:
       typed_config:
         "@type": type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext
         common_tls_context:
+          {{- if .AgentCAPEM }}
           validation_context:
             trusted_ca:
-              inline_string: "{{ .AgentCAPEM }}"
+              inline_string: {{ printf "%q" .AgentCAPEM }}
+          {{- else }} {}
+          {{- end }}
     {{- end }}
+  {{- if .PrometheusPort }}
+  - name: self_admin
+    ignore_health_on_host_removal: false
+    connect_timeout: 5s
+    type: STATIC
+    http_protocol_options: {}
+    loadAssignment:
+      clusterName: self_admin
+      endpoints:
+      - lbEndpoints:
+        - endpoint:
+            address:
+              socket_address:
+                address: {{ .AdminClusterAddress }}
+                port_value: {{ .AdminPort }}
+  listeners:
+  - name: envoy_prometheus_metrics_listener
+    address:
+      socket_address:
+        address: {{ .PrometheusAddress }}
+        port_value: {{ .PrometheusPort }}
+    filter_chains:
+    - filters:
+      - name: envoy.filters.network.http_connection_manager
+        typedConfig:
+          "@type": type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager
+          stat_prefix: envoy_prometheus_metrics
+          codec_type: HTTP1
+          route_config:
+            name: self_admin_route
+            virtual_hosts:
+            - name: self_admin
+              domains:
+              - "*"
+              routes:
+              - match:
+                  path: "/metrics"
+                route:
+                  cluster: self_admin
+                  prefix_rewrite: "/stats/prometheus"
+              - match:
+                  prefix: "/"
+                direct_response:
+                  status: 404
+          http_filters:
+          - name: envoy.filters.http.router
+            typedConfig:
+              "@type": type.googleapis.com/envoy.extensions.filters.http.router.v3.Router
+  {{- end }}
 dynamic_resources:
   lds_config:
     ads: {}
@@ -54,7 +106,7 @@ dynamic_resources:
     grpc_services:
       initial_metadata:
       - key: x-consul-token
-        value: "{{ .ConsulToken }}"
+        value: {{ printf "%q" .ConsulToken }}
       envoy_grpc:
         cluster_name: local_agent
 stats_config:
@@ -114,9 +166,9 @@ stats_config:
   - tag_name: consul.source.service
     fixed_value: {{.ServiceName}}
   - tag_name: consul.source.namespace
-    fixed_value: default
+    fixed_value: {{.Namespace}}
   - tag_name: consul.source.partition
-    fixed_value: default
+    fixed_value: {{.Partition}}
   - tag_name: consul.source.datacenter
-    fixed_value: dc1
+    fixed_value: {{.Datacenter}}
   use_all_default_tags: true
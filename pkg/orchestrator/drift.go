@@ -0,0 +1,213 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arnarg/mads/pkg/entities"
+)
+
+// Drift describes how a running pod differs from its definition.
+type Drift struct {
+	Reasons []string
+	// Recreate is set when applying the pod again isn't enough to repair it
+	// and it has to be recreated.
+	Recreate bool
+}
+
+// Drifted returns true if the running pod differs from its definition.
+func (d *Drift) Drifted() bool {
+	return len(d.Reasons) > 0
+}
+
+// Drift compares a pod definition with the pod running in podman and
+// its services registered in consul.
+func (o *Orchestrator) Drift(ctx context.Context, pod *entities.Pod) (*Drift, error) {
+	drift := &Drift{}
+
+	// Find the current instance of the pod
+	current, err := o.findPod(ctx, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		drift.Reasons = append(drift.Reasons, "pod does not exist")
+		return drift, nil
+	}
+
+	// Check if configuration has changed
+	set, err := o.resolveSecrets(ctx, pod)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve secrets for pod '%s': %s", pod.Name, err)
+	}
+	currHash, err := podHash(pod, set)
+	if err != nil {
+		return nil, fmt.Errorf("could not compute hash for pod '%s': %s", pod.Name, err)
+	}
+	if appliedHash(current) != currHash {
+		drift.Reasons = append(drift.Reasons, "configuration has changed")
+		return drift, nil
+	}
+
+	// Check pod state
+	running, err := o.podRunning(ctx, current)
+	if err != nil {
+		return nil, fmt.Errorf("could not get state of pod '%s': %s", current.Name, err)
+	}
+	if !running {
+		drift.Reasons = append(drift.Reasons, fmt.Sprintf("pod is %s", current.State))
+	}
+
+	// Check that all containers exist
+	ctrNames := map[string]bool{}
+	for _, c := range current.Containers {
+		ctrNames[c.Name] = true
+	}
+	for _, name := range expectedContainers(pod) {
+		if !ctrNames[fmt.Sprintf("%s-%s", current.Name, name)] {
+			drift.Reasons = append(drift.Reasons, fmt.Sprintf("container '%s' is missing", name))
+			drift.Recreate = true
+		}
+	}
+
+	// Check that all services are registered in consul
+	services, err := o.cclient.Agent().Services()
+	if err != nil {
+		return nil, fmt.Errorf("could not list consul services: %s", err)
+	}
+	for _, id := range splitServiceIDs(current) {
+		if _, ok := services[id]; !ok {
+			drift.Reasons = append(drift.Reasons, fmt.Sprintf("consul service '%s' is not registered", id))
+		}
+	}
+
+	return drift, nil
+}
+
+// Recreate deletes the current instance of a pod and creates it again from its definition.
+// Unlike deleting and applying the pod, volumes and secrets of the pod are kept.
+func (o *Orchestrator) Recreate(ctx context.Context, pod *entities.Pod) error {
+	// Validate pod definition
+	err := validatePod(pod)
+	if err != nil {
+		return fmt.Errorf("invalid pod '%s': %s", pod.Name, err)
+	}
+
+	// Find the current instance of the pod
+	current, err := o.findPod(ctx, pod.Name)
+	if err != nil {
+		return err
+	}
+
+	// Pod doesn't exist so there is nothing to recreate
+	if current == nil {
+		return o.Apply(ctx, pod)
+	}
+
+	// No last applied configuration is present, we refuse to recreate
+	if _, ok := current.Labels[lastAppliedLabel]; !ok {
+		return fmt.Errorf("pod '%s' has no mads label, will not recreate", pod.Name)
+	}
+
+	// Compute hash for current configuration
+	set, err := o.resolveSecrets(ctx, pod)
+	if err != nil {
+		return fmt.Errorf("could not resolve secrets for pod '%s': %s", pod.Name, err)
+	}
+	currHash, err := podHash(pod, set)
+	if err != nil {
+		return fmt.Errorf("could not compute hash for pod '%s': %s", pod.Name, err)
+	}
+
+	return o.recreatePod(ctx, pod, current, currHash, set)
+}
+
+// ManagedPods returns the names of all pods managed by mads.
+func (o *Orchestrator) ManagedPods(ctx context.Context) ([]string, error) {
+	// Find all pods with the mads label
+	list, err := o.pclient.Pods().List(ctx, map[string][]string{
+		"label": {lastAppliedLabel},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list pods: %s", err)
+	}
+
+	names := []string{}
+	for _, entry := range list {
+		// Pods created by older versions of mads don't have the pod name label
+		name := entry.Labels[podNameLabel]
+		if name == "" {
+			name = entry.Name
+		}
+
+		// Both instances of a pod can exist during a replacement
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// ManagedServices returns the IDs of all consul services registered by mads
+// mapped to the name of the pod they belong to.
+func (o *Orchestrator) ManagedServices(ctx context.Context) (map[string]string, error) {
+	services, err := o.cclient.Agent().ServicesWithFilter(fmt.Sprintf("Meta.%s == \"true\"", managedServiceMeta))
+	if err != nil {
+		return nil, fmt.Errorf("could not list consul services: %s", err)
+	}
+
+	ids := map[string]string{}
+	for id, svc := range services {
+		ids[id] = svc.Meta[servicePodNameMeta]
+	}
+
+	return ids, nil
+}
+
+// DeregisterService deregisters a single consul service.
+func (o *Orchestrator) DeregisterService(ctx context.Context, id string) error {
+	return o.deregisterServices(ctx, []string{id})
+}
+
+// ManagedVolumes returns the names of all volumes that mads created and should be
+// deleted along with their pod, mapped to the name of the pod they belong to.
+func (o *Orchestrator) ManagedVolumes(ctx context.Context) (map[string]string, error) {
+	list, err := o.pclient.Volumes().List(ctx, map[string][]string{
+		"label": {fmt.Sprintf("%s=true", managedLabel)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list volumes: %s", err)
+	}
+
+	names := map[string]string{}
+	for _, vol := range list {
+		// Volumes created by older versions of mads have no retention policy label and are kept
+		if vol.Labels[retentionLabel] != entities.VolumeRetentionDelete || vol.Labels[podNameLabel] == "" {
+			continue
+		}
+		names[vol.Name] = vol.Labels[podNameLabel]
+	}
+
+	return names, nil
+}
+
+// RemoveVolume deletes a single volume, it fails if the volume is still in use.
+func (o *Orchestrator) RemoveVolume(ctx context.Context, name string) error {
+	return o.pclient.Volumes().Delete(ctx, name, false)
+}
+
+// expectedContainers returns the names of all containers that should be in a pod,
+// including sidecar proxies.
+func expectedContainers(pod *entities.Pod) []string {
+	names := []string{}
+	for _, ctr := range pod.Containers {
+		names = append(names, ctr.Name)
+	}
+	for _, svc := range pod.Services {
+		if !svc.Connect.Native && svc.Connect.SidecarService != nil {
+			names = append(names, fmt.Sprintf("%s-sidecar-proxy", svc.Name))
+		}
+	}
+	return names
+}
@@ -0,0 +1,204 @@
+package orchestrator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/arnarg/mads/pkg/podman/containers"
+	"github.com/hashicorp/consul/api"
+)
+
+// podmanHealthConfig converts a container healthcheck into podman's native healthcheck config.
+// HTTP and TCP checks are run inside the container and therefore need curl and nc in the image,
+// unless they are only run by consul.
+func podmanHealthConfig(hc *entities.ContainerHealthCheck) *containers.HealthConfig {
+	if hc == nil || hc.ConsulOnly {
+		return nil
+	}
+
+	cfg := &containers.HealthConfig{
+		Interval:    time.Duration(hc.Interval),
+		Timeout:     time.Duration(hc.Timeout),
+		StartPeriod: time.Duration(hc.StartPeriod),
+		Retries:     hc.Retries,
+	}
+
+	switch {
+	case len(hc.Exec) > 0:
+		cfg.Test = append([]string{"CMD"}, hc.Exec...)
+	case hc.HTTP != nil:
+		cfg.Test = []string{
+			"CMD-SHELL",
+			fmt.Sprintf("curl -fsS -o /dev/null %s://127.0.0.1:%d%s || exit 1", hc.HTTP.Scheme, hc.HTTP.Port, hc.HTTP.Path),
+		}
+	case hc.TCP != nil:
+		cfg.Test = []string{
+			"CMD-SHELL",
+			fmt.Sprintf("nc -z 127.0.0.1 %d || exit 1", hc.TCP.Port),
+		}
+	}
+
+	return cfg
+}
+
+// consulChecks converts the healthchecks of the containers backing a service into consul service checks.
+// HTTP and TCP checks are run by the consul agent against the port published on the host,
+// exec checks are run as script checks through `podman healthcheck run`, which requires
+// the consul agent to have local script checks enabled.
+func (o *Orchestrator) consulChecks(pod *entities.Pod, instance string, svc *entities.Service) api.AgentServiceChecks {
+	checks := api.AgentServiceChecks{}
+
+	for _, ctr := range serviceContainers(pod, svc) {
+		hc := ctr.HealthCheck
+		if hc == nil {
+			continue
+		}
+
+		ctrName := fmt.Sprintf("%s-%s", instance, ctr.Name)
+		check := &api.AgentServiceCheck{
+			Name:     fmt.Sprintf("Container '%s' health", ctr.Name),
+			Interval: hc.Interval.String(),
+			Timeout:  hc.Timeout.String(),
+		}
+
+		switch {
+		case len(hc.Exec) > 0:
+			check.Args = []string{
+				"podman",
+				"--url", fmt.Sprintf("unix://%s", o.podmanSocket),
+				"healthcheck", "run", ctrName,
+			}
+		case hc.HTTP != nil:
+			addr, ok := hostAddress(pod, &ctr, hc.HTTP.Port)
+			if !ok {
+				continue
+			}
+			check.HTTP = fmt.Sprintf("%s://%s%s", hc.HTTP.Scheme, addr, hc.HTTP.Path)
+		case hc.TCP != nil:
+			addr, ok := hostAddress(pod, &ctr, hc.TCP.Port)
+			if !ok {
+				continue
+			}
+			check.TCP = addr
+		default:
+			continue
+		}
+
+		checks = append(checks, check)
+	}
+
+	return checks
+}
+
+// serviceContainers returns the containers backing a service, either the ones listed
+// in the service or the ones with a port mapping for the service port.
+func serviceContainers(pod *entities.Pod, svc *entities.Service) []entities.Container {
+	ctrs := []entities.Container{}
+
+	for _, ctr := range pod.Containers {
+		if len(svc.Containers) > 0 {
+			if contains(svc.Containers, ctr.Name) {
+				ctrs = append(ctrs, ctr)
+			}
+			continue
+		}
+
+		for _, mapping := range ctr.Ports {
+			if svc.Port != 0 && int(mapping.ContainerPort) == svc.Port {
+				ctrs = append(ctrs, ctr)
+				break
+			}
+		}
+	}
+
+	return ctrs
+}
+
+// hostAddress finds the host address a container port is published on.
+// It returns false if the port isn't reachable from the host.
+func hostAddress(pod *entities.Pod, ctr *entities.Container, port uint16) (string, bool) {
+	switch networkMode(pod) {
+	// Ports aren't published with the host network, the container listens on the host directly
+	case entities.NetworkModeHost:
+		return fmt.Sprintf("127.0.0.1:%d", port), true
+	// Nothing is reachable without a network
+	case entities.NetworkModeNone:
+		return "", false
+	}
+
+	for _, mapping := range ctr.Ports {
+		// Ports without a host port get a random one from podman
+		if mapping.ContainerPort != port || mapping.HostPort == 0 {
+			continue
+		}
+
+		host := mapping.HostIP
+		if host == "" || host == "0.0.0.0" {
+			host = "127.0.0.1"
+		}
+
+		return fmt.Sprintf("%s:%d", host, mapping.HostPort), true
+	}
+
+	return "", false
+}
+
+// validateConsulChecks makes sure that the ports of HTTP and TCP healthchecks,
+// which are also run by the consul agent, are reachable from the host.
+func validateConsulChecks(pod *entities.Pod) error {
+	for _, svc := range pod.Services {
+		for _, ctr := range serviceContainers(pod, &svc) {
+			hc := ctr.HealthCheck
+			if hc == nil {
+				continue
+			}
+
+			var port uint16
+			switch {
+			case hc.HTTP != nil:
+				port = hc.HTTP.Port
+			case hc.TCP != nil:
+				port = hc.TCP.Port
+			default:
+				continue
+			}
+
+			if _, ok := hostAddress(pod, &ctr, port); !ok {
+				return fmt.Errorf("container '%s': healthcheck port %d must be published with a host port for consul to check it", ctr.Name, port)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateHealthCheck(hc *entities.ContainerHealthCheck) error {
+	set := 0
+	if len(hc.Exec) > 0 {
+		set++
+	}
+	if hc.HTTP != nil {
+		if hc.HTTP.Port == 0 {
+			return fmt.Errorf("http healthcheck needs a port")
+		}
+		set++
+	}
+	if hc.TCP != nil {
+		if hc.TCP.Port == 0 {
+			return fmt.Errorf("tcp healthcheck needs a port")
+		}
+		set++
+	}
+
+	if set != 1 {
+		return fmt.Errorf("exactly one of exec, http or tcp must be set in healthcheck")
+	}
+
+	// Exec checks are only run by podman
+	if hc.ConsulOnly && len(hc.Exec) > 0 {
+		return fmt.Errorf("consulOnly can't be used with exec healthchecks")
+	}
+
+	return nil
+}
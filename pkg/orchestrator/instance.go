@@ -0,0 +1,659 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/arnarg/mads/pkg/podman/containers"
+	"github.com/arnarg/mads/pkg/podman/pods"
+)
+
+// A pod alternates between two podman pod names, its own name and the name
+// with this suffix, every time it is replaced with the rolling strategy.
+// This is because podman pods can't be renamed and both instances need to
+// exist at the same time during a replacement.
+const replacementSuffix = "-next"
+
+// Reason shown in consul for services of a new instance that isn't ready yet
+const maintenanceReason = "mads: waiting for pod to become ready"
+
+// instance is a prepared, but not yet created, podman pod for a mads pod.
+type instance struct {
+	name           string
+	svcIDs         []string
+	initContainers []entities.Container
+	containers     []entities.Container
+	imageIDs       map[string]string
+	secrets        secretSet
+	adminPorts     adminPorts
+}
+
+// instanceNames returns the podman pod names that an instance of a pod can have.
+func instanceNames(name string) []string {
+	return []string{name, name + replacementSuffix}
+}
+
+// otherInstanceName returns the name a replacement for the current instance should get.
+func otherInstanceName(name, current string) string {
+	if current == name {
+		return name + replacementSuffix
+	}
+	return name
+}
+
+// findInstances returns all existing podman pods belonging to a mads pod.
+func (o *Orchestrator) findInstances(ctx context.Context, name string) ([]*pods.PodInfo, error) {
+	instances := []*pods.PodInfo{}
+
+	for _, iname := range instanceNames(name) {
+		// Check if a pod with the instance name exists
+		exists, id, err := o.pclient.Pods().Exists(ctx, iname)
+		if err != nil {
+			return nil, fmt.Errorf("could not check if pod exists: %s", err)
+		}
+		if !exists {
+			continue
+		}
+
+		// Get pod info
+		info, err := o.pclient.Pods().Inspect(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		// Make sure the pod actually belongs to this mads pod and not
+		// another one that happens to be named like a replacement.
+		// Pods created before the pod name label was introduced only
+		// ever have the plain pod name.
+		if pname, ok := info.Labels[podNameLabel]; ok && pname != name {
+			continue
+		}
+		if _, ok := info.Labels[podNameLabel]; !ok && iname != name {
+			continue
+		}
+
+		instances = append(instances, info)
+	}
+
+	return instances, nil
+}
+
+// findPod returns the current instance of a mads pod or nil if none exists.
+func (o *Orchestrator) findPod(ctx context.Context, name string) (*pods.PodInfo, error) {
+	instances, err := o.findInstances(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(instances) < 1 {
+		return nil, nil
+	}
+
+	return instances[0], nil
+}
+
+// registerServices registers all services of a pod instance in consul and
+// returns their IDs along with sidecar containers that should be added to the pod.
+// With maintenance set the services are put in maintenance mode right away so they
+// don't get any traffic until the instance is ready.
+func (o *Orchestrator) registerServices(ctx context.Context, pod *entities.Pod, name string, set secretSet, ports adminPorts, maintenance bool) ([]string, []entities.Container, error) {
+	svcIDs := []string{}
+	sidecars := []entities.Container{}
+
+	for _, svc := range pod.Services {
+		id, ctr, err := o.createService(ctx, pod, name, &svc, set, ports[svc.Name])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if maintenance {
+			err := o.cclient.Agent().EnableServiceMaintenance(id, maintenanceReason)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not enable maintenance mode of consul service '%s': %s", id, err)
+			}
+		}
+
+		// Add sidecar container to pod
+		if ctr != nil {
+			sidecars = append(sidecars, *ctr)
+		}
+
+		// Add to list of services
+		svcIDs = append(svcIDs, id)
+	}
+
+	return svcIDs, sidecars, nil
+}
+
+// prepareInstance registers services, realizes all images and creates secrets, networks
+// and volumes for a new pod instance. No pods are changed in podman until the instance is created.
+// The current instance, if any, is used to keep the allocated envoy admin ports.
+// With maintenance set the services are registered in maintenance mode.
+func (o *Orchestrator) prepareInstance(ctx context.Context, pod *entities.Pod, name string, set secretSet, current *pods.PodInfo, maintenance bool) (*instance, error) {
+	// Get ports used by other pods
+	used, err := o.usedPorts(ctx, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Allocate envoy admin ports, keeping the ones of the current instance
+	var currentPorts adminPorts
+	if current != nil {
+		currentPorts = parseAdminPorts(current.Labels[adminPortsLabel])
+	}
+	ports := allocateAdminPorts(pod, currentPorts, used)
+
+	// Create services
+	svcIDs, sidecars, err := o.registerServices(ctx, pod, name, set, ports, maintenance)
+	if err != nil {
+		return nil, err
+	}
+
+	inst := &instance{
+		name:           name,
+		svcIDs:         svcIDs,
+		initContainers: pod.InitContainers,
+		containers:     append(append([]entities.Container{}, pod.Containers...), sidecars...),
+		imageIDs:       map[string]string{},
+		secrets:        set,
+		adminPorts:     ports,
+	}
+
+	// Make sure no other pod uses the same host ports
+	err = checkHostPorts(pod, inst.containers, used)
+	if err != nil {
+		o.cleanupServices(ctx, pod.Name, name, svcIDs)
+
+		return nil, err
+	}
+
+	// Get all images before touching any pods so a failed pull
+	// doesn't leave us without a pod
+	for _, ctr := range append(append([]entities.Container{}, inst.initContainers...), inst.containers...) {
+		if _, ok := inst.imageIDs[ctr.Image]; ok {
+			continue
+		}
+
+		imageID, err := realizeImage(ctx, o.pclient, ctr.Image, ctr.ImagePullPolicy)
+		if err != nil {
+			// Deregister any services that only this instance uses (best effort)
+			o.cleanupServices(ctx, pod.Name, name, svcIDs)
+
+			return nil, err
+		}
+
+		inst.imageIDs[ctr.Image] = imageID
+	}
+
+	// Create podman secrets for referenced values
+	err = o.createSecrets(ctx, pod, set)
+	if err != nil {
+		o.cleanupServices(ctx, pod.Name, name, svcIDs)
+
+		return nil, err
+	}
+
+	// Create networks the pod is attached to
+	err = o.createNetworks(ctx, pod)
+	if err != nil {
+		o.cleanupServices(ctx, pod.Name, name, svcIDs)
+
+		return nil, err
+	}
+
+	// Create volumes before any containers use them
+	err = o.createVolumes(ctx, pod)
+	if err != nil {
+		o.cleanupServices(ctx, pod.Name, name, svcIDs)
+
+		return nil, err
+	}
+
+	return inst, nil
+}
+
+// createInstance creates a podman pod with all of its containers.
+func (o *Orchestrator) createInstance(ctx context.Context, pod *entities.Pod, inst *instance, hash string) (string, error) {
+	// Add service IDs to pod labels
+	podLabels := map[string]string{
+		serviceIDsLabel: strings.Join(inst.svcIDs, ","),
+	}
+	for k, v := range pod.Labels {
+		podLabels[k] = v
+	}
+
+	// Save a snapshot of the configuration so it can be read back later
+	snap, err := entities.EncodeSnapshot(pod)
+	if err != nil {
+		return "", fmt.Errorf("could not create snapshot of pod '%s': %s", pod.Name, err)
+	}
+
+	// Save configuration, hash and pod name labels
+	podLabels[lastAppliedLabel] = snap
+	podLabels[configHashLabel] = hash
+	podLabels[podNameLabel] = pod.Name
+
+	// Save envoy admin ports so they are kept across applies
+	if len(inst.adminPorts) > 0 {
+		podLabels[adminPortsLabel] = inst.adminPorts.String()
+	}
+
+	// Save host ports so other pods can check for conflicts without inspecting this one
+	podLabels[hostPortsLabel] = formatHostPorts(podHostPorts(pod, inst.containers))
+
+	// Create pod creation request
+	req := &pods.PodCreateRequest{
+		Name:           inst.name,
+		Hostname:       pod.Hostname,
+		Labels:         podLabels,
+		ResourceLimits: podmanResources(pod.Resources),
+		// User namespaces can only be set on the pod
+		UserNS: podUserNS(userContainers(pod)),
+	}
+
+	// Apply network settings
+	applyNetwork(req, pod)
+
+	// Apply hosts
+	for host, ip := range pod.Hosts {
+		req.HostAdd = append(req.HostAdd, fmt.Sprintf("%s:%s", host, ip))
+	}
+
+	// Take various config from containers that needs to be set on pod level
+	for _, ctr := range inst.containers {
+		// Apply port mappings from all containers.
+		// Ports can only be mapped with a bridged network.
+		if networkMode(pod) != entities.NetworkModeBridge {
+			continue
+		}
+		for _, mapping := range ctr.Ports {
+			req.PortMappings = append(req.PortMappings, pods.PodPortMapping{
+				HostIP:        mapping.HostIP,
+				HostPort:      mapping.HostPort,
+				ContainerPort: mapping.ContainerPort,
+				Protocol:      mapping.Protocol,
+			})
+		}
+	}
+
+	// Create pod
+	id, err := o.pclient.Pods().Create(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("could not create pod '%s': %s", inst.name, err)
+	}
+
+	// Create init containers first, podman runs them in the order they are created
+	for _, ctr := range inst.initContainers {
+		ctrName := fmt.Sprintf("%s-%s", inst.name, ctr.Name)
+		err := o.createContainer(ctx, ctrName, id, inst.imageIDs[ctr.Image], &ctr, initPolicy(pod), inst.secrets)
+		if err != nil {
+			// Delete pod to cleanup (best effort)
+			o.pclient.Pods().Delete(ctx, id, true)
+
+			return "", fmt.Errorf("could not create init container '%s' in pod '%s': %s", ctr.Name, inst.name, err)
+		}
+	}
+
+	// Since we just created a new pod we need to create all of its containers
+	for _, ctr := range inst.containers {
+		// Create container
+		ctrName := fmt.Sprintf("%s-%s", inst.name, ctr.Name)
+		err := o.createContainer(ctx, ctrName, id, inst.imageIDs[ctr.Image], &ctr, "", inst.secrets)
+		if err != nil {
+			// Delete pod to cleanup (best effort)
+			o.pclient.Pods().Delete(ctx, id, true)
+
+			return "", fmt.Errorf("could not create container '%s' in pod '%s': %s", ctr.Name, inst.name, err)
+		}
+	}
+
+	return id, nil
+}
+
+// startPod starts a pod if it's not already running.
+func (o *Orchestrator) startPod(ctx context.Context, name string) error {
+	// Get pod info
+	info, err := o.pclient.Pods().Inspect(ctx, name)
+	if err != nil {
+		return fmt.Errorf("could not get info for pod '%s': %s", name, err)
+	}
+
+	// Check if pod is already running
+	running, err := o.podRunning(ctx, info)
+	if err != nil {
+		return err
+	}
+
+	// Start pod
+	if !running {
+		err := o.pclient.Pods().Start(ctx, name)
+		if err != nil && err != pods.ErrPodAlreadyStarted {
+			// Podman doesn't tell us which init container failed so we look for it
+			if ierr := o.initContainerError(ctx, info); ierr != nil {
+				return fmt.Errorf("could not start pod '%s': %s", name, ierr)
+			}
+			return fmt.Errorf("could not start pod '%s': %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// podRunning checks if all containers in a pod, except for init containers, are running.
+// Podman doesn't consider a pod with exited init containers to be fully running.
+// Containers that exited and aren't restarted due to their restart policy are ignored.
+func (o *Orchestrator) podRunning(ctx context.Context, info *pods.PodInfo) (bool, error) {
+	if info.State == pods.PodStateRunning {
+		return true, nil
+	}
+	if len(info.Containers) < 1 {
+		return false, nil
+	}
+
+	for _, c := range info.Containers {
+		cinfo, err := o.pclient.Containers().Inspect(ctx, c.Id)
+		if err != nil {
+			return false, err
+		}
+
+		if cinfo.Config.Labels[initContainerLabel] == "true" {
+			continue
+		}
+		if cinfo.State.Status != containers.StateRunning && shouldRun(cinfo) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// shouldRun checks if a container that isn't running should be.
+// Containers that exited are only expected to run again if their restart
+// policy would have podman restart them.
+func shouldRun(cinfo *containers.ContainerInfo) bool {
+	if cinfo.IsInfra || cinfo.State.Status != containers.StateExited {
+		return true
+	}
+
+	switch cinfo.HostConfig.RestartPolicy.Name {
+	case containers.RestartPolicyAlways, containers.RestartPolicyUnlessStopped:
+		return true
+	case containers.RestartPolicyOnFailure:
+		return cinfo.State.ExitCode != 0
+	}
+
+	return false
+}
+
+// Number of log lines of a failed init container to include in the error
+const initContainerLogLines = 20
+
+// initContainerError returns an error with the exit code and last log lines
+// of the first init container in a pod that exited unsuccessfully, if any.
+func (o *Orchestrator) initContainerError(ctx context.Context, info *pods.PodInfo) error {
+	for _, c := range info.Containers {
+		cinfo, err := o.pclient.Containers().Inspect(ctx, c.Id)
+		if err != nil {
+			continue
+		}
+
+		if cinfo.Config.Labels[initContainerLabel] != "true" {
+			continue
+		}
+		if cinfo.State.Status != containers.StateExited || cinfo.State.ExitCode == 0 {
+			continue
+		}
+
+		// Get the last log lines (best effort)
+		logs, err := o.pclient.Containers().Logs(ctx, c.Id, initContainerLogLines)
+		if err != nil {
+			logs = fmt.Sprintf("could not get logs: %s", err)
+		}
+
+		name := strings.TrimPrefix(c.Name, info.Name+"-")
+		return fmt.Errorf("init container '%s' exited with code %d:\n%s", name, cinfo.State.ExitCode, strings.TrimRight(logs, "\n"))
+	}
+
+	return nil
+}
+
+// recreatePod deletes the current instance of a pod and creates a new one in its place.
+func (o *Orchestrator) recreatePod(ctx context.Context, pod *entities.Pod, current *pods.PodInfo, hash string, set secretSet) error {
+	// Register services and get images before deleting anything
+	inst, err := o.prepareInstance(ctx, pod, current.Name, set, current, false)
+	if err != nil {
+		return err
+	}
+
+	// Delete old pod, keeping the services the new pod uses
+	err = o.removeInstance(ctx, current, inst.svcIDs)
+	if err != nil {
+		return err
+	}
+
+	// Create new pod
+	_, err = o.createInstance(ctx, pod, inst, hash)
+	if err != nil {
+		return err
+	}
+
+	return o.startPod(ctx, inst.name)
+}
+
+// replacePod creates a new instance of a pod next to the current one and only removes
+// the current one once the new one is ready. The services of the new instance are in
+// maintenance mode until then so they don't get traffic before the pod exists.
+// If the new instance fails it is removed and the current instance is left running.
+func (o *Orchestrator) replacePod(ctx context.Context, pod *entities.Pod, current *pods.PodInfo, hash string, set secretSet) error {
+	name := otherInstanceName(pod.Name, current.Name)
+	currentIDs := splitServiceIDs(current)
+
+	// Remove a leftover instance from an interrupted replacement
+	instances, err := o.findInstances(ctx, pod.Name)
+	if err != nil {
+		return err
+	}
+	for _, leftover := range instances {
+		if leftover.Name == name {
+			err := o.removeInstance(ctx, leftover, currentIDs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Register services under new IDs and get images.
+	// Services are kept in maintenance mode until the new pod is ready.
+	inst, err := o.prepareInstance(ctx, pod, name, set, current, true)
+	if err != nil {
+		return err
+	}
+
+	// Create and start the new pod
+	id, err := o.createInstance(ctx, pod, inst, hash)
+	if err == nil {
+		err = o.startPod(ctx, id)
+	}
+	if err == nil {
+		err = o.waitReady(ctx, id, time.Duration(pod.UpdateStrategy.Timeout))
+	}
+	if err == nil {
+		err = o.disableMaintenance(inst.svcIDs)
+	}
+
+	// Roll back the new instance and leave the current one running
+	if err != nil {
+		o.pclient.Pods().Delete(ctx, name, true)
+		o.cleanupServices(ctx, pod.Name, name, inst.svcIDs)
+
+		return fmt.Errorf("could not replace pod '%s', keeping the current one: %s", pod.Name, err)
+	}
+
+	// New pod is ready, remove the old one along with its consul services
+	return o.removeInstance(ctx, current, inst.svcIDs)
+}
+
+// waitReady waits for all containers in a pod to be running and healthy.
+func (o *Orchestrator) waitReady(ctx context.Context, nameOrID string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		ready, reason, err := o.podReady(ctx, nameOrID)
+		if err != nil {
+			return err
+		}
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("pod did not become ready within %s: %s", timeout, reason)
+		}
+	}
+}
+
+// podReady checks if all containers in a pod are running and healthy.
+// If the pod is not ready the reason is returned.
+func (o *Orchestrator) podReady(ctx context.Context, nameOrID string) (bool, string, error) {
+	info, err := o.pclient.Pods().Inspect(ctx, nameOrID)
+	if err != nil {
+		return false, "", err
+	}
+
+	for _, c := range info.Containers {
+		// Skip the infra container
+		if c.Id == info.InfraContainerID {
+			continue
+		}
+
+		cinfo, err := o.pclient.Containers().Inspect(ctx, c.Id)
+		if err != nil {
+			return false, "", err
+		}
+
+		// Init containers have already run to completion when the pod started
+		if cinfo.Config.Labels[initContainerLabel] == "true" {
+			continue
+		}
+
+		if cinfo.State.Status != containers.StateRunning {
+			return false, fmt.Sprintf("container '%s' is %s", c.Name, cinfo.State.Status), nil
+		}
+
+		switch cinfo.State.HealthStatus() {
+		// An unhealthy container has used up all its retries so we fail early
+		case containers.HealthStatusUnhealthy:
+			return false, "", fmt.Errorf("container '%s' is unhealthy", c.Name)
+		case containers.HealthStatusStarting:
+			return false, fmt.Sprintf("container '%s' health is starting", c.Name), nil
+		}
+	}
+
+	return true, "", nil
+}
+
+// removeInstance deregisters all services of a pod instance, except the ones
+// in keep, and force deletes the pod.
+func (o *Orchestrator) removeInstance(ctx context.Context, info *pods.PodInfo, keep []string) error {
+	// Deregister services
+	err := o.deregisterServices(ctx, excludeIDs(splitServiceIDs(info), keep))
+	if err != nil {
+		return err
+	}
+
+	// Delete podman pod.
+	// Only pods with the last-applied-configuration label are passed here so we can just force delete it.
+	err = o.pclient.Pods().Delete(ctx, info.Id, true)
+	if err != nil {
+		return fmt.Errorf("could not delete pod '%s': %s", info.Name, err)
+	}
+
+	return nil
+}
+
+// disableMaintenance takes consul services out of maintenance mode.
+func (o *Orchestrator) disableMaintenance(svcIDs []string) error {
+	for _, id := range svcIDs {
+		err := o.cclient.Agent().DisableServiceMaintenance(id)
+		if err != nil {
+			return fmt.Errorf("could not disable maintenance mode of consul service '%s': %s", id, err)
+		}
+	}
+
+	return nil
+}
+
+// cleanupServices deregisters services of a failed instance that the current
+// instance of the pod isn't using (best effort).
+func (o *Orchestrator) cleanupServices(ctx context.Context, podName, name string, svcIDs []string) {
+	keep := []string{}
+	if current, err := o.findPod(ctx, podName); err == nil && current != nil && current.Name != name {
+		keep = splitServiceIDs(current)
+	}
+
+	o.deregisterServices(ctx, excludeIDs(svcIDs, keep))
+}
+
+func (o *Orchestrator) deregisterServices(ctx context.Context, svcIDs []string) error {
+	for _, svc := range svcIDs {
+		err := o.cclient.Agent().ServiceDeregister(svc)
+		// If we get a 404 we might have already deregistered it in a previous run
+		// but it doesn't matter and we'll just continue.
+		if err != nil && !strings.Contains(err.Error(), "Unknown service ID") {
+			return fmt.Errorf("could not deregister consul service '%s': %s", svc, err)
+		}
+	}
+
+	return nil
+}
+
+// appliedHash returns the configuration hash of a pod instance.
+// Pods applied by older versions of mads don't have the hash label, their hash is
+// computed from the last applied configuration instead so they aren't replaced just
+// because mads was upgraded. Podman can't change the labels of an existing pod so
+// they get the label the next time they are replaced.
+func appliedHash(info *pods.PodInfo) string {
+	if hash, ok := info.Labels[configHashLabel]; ok {
+		return hash
+	}
+
+	snap, err := entities.DecodeSnapshot(info.Labels[lastAppliedLabel])
+	if err != nil || snap.Pod == nil {
+		return ""
+	}
+
+	// Older versions of mads didn't support values stored outside of the
+	// pod definition, so the hash doesn't depend on any
+	hash, err := snap.Pod.Hash()
+	if err != nil {
+		return ""
+	}
+
+	return hash
+}
+
+func splitServiceIDs(info *pods.PodInfo) []string {
+	if svcList, ok := info.Labels[serviceIDsLabel]; ok && svcList != "" {
+		return strings.Split(svcList, ",")
+	}
+	return []string{}
+}
+
+func excludeIDs(ids, exclude []string) []string {
+	res := []string{}
+	for _, id := range ids {
+		if !contains(exclude, id) {
+			res = append(res, id)
+		}
+	}
+	return res
+}
@@ -0,0 +1,143 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/arnarg/mads/pkg/podman/containers"
+	"github.com/arnarg/mads/pkg/podman/networks"
+	"github.com/arnarg/mads/pkg/podman/pods"
+)
+
+// networkMode returns the network mode of a pod.
+func networkMode(pod *entities.Pod) string {
+	if pod.Network == nil || pod.Network.Mode == "" {
+		return entities.NetworkModeBridge
+	}
+	return pod.Network.Mode
+}
+
+// applyNetwork sets the network settings of a pod creation request.
+func applyNetwork(req *pods.PodCreateRequest, pod *entities.Pod) {
+	if pod.Network == nil {
+		return
+	}
+
+	req.NetNS = &containers.Namespace{NSMode: networkMode(pod)}
+
+	// Attach to networks
+	for _, n := range pod.Network.Networks {
+		if req.Networks == nil {
+			req.Networks = map[string]pods.PerNetworkOptions{}
+		}
+
+		opts := pods.PerNetworkOptions{
+			StaticMAC: n.MAC,
+			Aliases:   n.Aliases,
+		}
+		if n.IP != "" {
+			opts.StaticIPs = []string{n.IP}
+		}
+
+		req.Networks[n.Name] = opts
+	}
+
+	// DNS settings
+	if dns := pod.Network.DNS; dns != nil {
+		req.DNSServer = dns.Servers
+		req.DNSSearch = dns.Search
+		req.DNSOption = dns.Options
+	}
+}
+
+// createNetworks creates all networks a pod is attached to that don't exist yet.
+func (o *Orchestrator) createNetworks(ctx context.Context, pod *entities.Pod) error {
+	if pod.Network == nil {
+		return nil
+	}
+
+	for _, n := range pod.Network.Networks {
+		exists, err := o.pclient.Networks().Exists(ctx, n.Name)
+		if err != nil {
+			return fmt.Errorf("could not check if network '%s' exists: %s", n.Name, err)
+		}
+		if exists {
+			continue
+		}
+
+		_, err = o.pclient.Networks().Create(ctx, &networks.NetworkCreateRequest{
+			Name:       n.Name,
+			DNSEnabled: true,
+			Labels: map[string]string{
+				managedLabel: "true",
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("could not create network '%s': %s", n.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func validateNetwork(pod *entities.Pod) error {
+	if pod.Network == nil {
+		return nil
+	}
+
+	mode := networkMode(pod)
+	switch mode {
+	case entities.NetworkModeBridge, entities.NetworkModeHost, entities.NetworkModeNone:
+	default:
+		return fmt.Errorf("unknown network mode '%s'", mode)
+	}
+
+	if mode != entities.NetworkModeBridge && len(pod.Network.Networks) > 0 {
+		return fmt.Errorf("networks can only be used with network mode '%s'", entities.NetworkModeBridge)
+	}
+
+	// Both pods would listen on the same host ports during a rolling replacement
+	if mode == entities.NetworkModeHost && pod.UpdateStrategy.Type == entities.UpdateStrategyRolling {
+		return fmt.Errorf("network mode '%s' can't be used with the rolling update strategy", entities.NetworkModeHost)
+	}
+
+	names := map[string]bool{}
+	for _, n := range pod.Network.Networks {
+		if n.Name == "" {
+			return fmt.Errorf("network has no name")
+		}
+		if names[n.Name] {
+			return fmt.Errorf("network '%s' is listed more than once", n.Name)
+		}
+		names[n.Name] = true
+
+		if n.IP != "" && net.ParseIP(n.IP) == nil {
+			return fmt.Errorf("network '%s': invalid ip '%s'", n.Name, n.IP)
+		}
+		if n.MAC != "" {
+			if _, err := net.ParseMAC(n.MAC); err != nil {
+				return fmt.Errorf("network '%s': invalid mac '%s'", n.Name, n.MAC)
+			}
+		}
+
+		// Both pods would need the same address during a rolling replacement
+		if (n.IP != "" || n.MAC != "") && pod.UpdateStrategy.Type == entities.UpdateStrategyRolling {
+			return fmt.Errorf("network '%s': static ip and mac can't be used with the rolling update strategy", n.Name)
+		}
+	}
+
+	if dns := pod.Network.DNS; dns != nil {
+		if mode == entities.NetworkModeHost {
+			return fmt.Errorf("dns can't be used with network mode '%s'", entities.NetworkModeHost)
+		}
+		for _, s := range dns.Servers {
+			if net.ParseIP(s) == nil {
+				return fmt.Errorf("invalid dns server '%s'", s)
+			}
+		}
+	}
+
+	return nil
+}
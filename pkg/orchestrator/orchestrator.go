@@ -25,12 +25,24 @@ import (
 
 const (
 	lastAppliedLabel   = "mads/last-applied-configuration"
+	configHashLabel    = "mads/config-hash"
 	serviceIDsLabel    = "mads/service-ids"
+	podNameLabel       = "mads/pod-name"
+	initContainerLabel = "mads/init-container"
+	managedLabel       = "mads/managed"
+	adminPortsLabel    = "mads/admin-ports"
+	hostPortsLabel     = "mads/host-ports"
+	retentionLabel     = "mads/retention-policy"
 	managedServiceMeta = "mads_managed"
 	servicePodNameMeta = "mads_pod_name"
+	metricsPortMeta    = "envoy_metrics_port"
+	metricsAddressMeta = "envoy_metrics_address"
 )
 
 type consulInfo struct {
+	Config struct {
+		Datacenter string
+	}
 	DebugConfig struct {
 		GRPCAddrs    []string
 		GRPCTLSAddrs []string
@@ -40,15 +52,22 @@ type consulInfo struct {
 type Config struct {
 	PodmanSocketPath string
 	EnvoyImage       string
+	// Consul client configuration, consul's default configuration is used if nil.
+	// The ACL token and CA are also used by envoy sidecars.
+	ConsulConfig *api.Config
 }
 
 type Orchestrator struct {
-	pclient    *podman.Client
-	cclient    *api.Client
-	envoyImage string
-	grpcAddr   string
-	grpcPort   uint16
-	grpcTLS    bool
+	pclient      *podman.Client
+	cclient      *api.Client
+	podmanSocket string
+	envoyImage   string
+	grpcAddr     string
+	grpcPort     uint16
+	grpcTLS      bool
+	consulToken  string
+	agentCAPEM   string
+	datacenter   string
 }
 
 func NewOrchestrator(cfg *Config) (*Orchestrator, error) {
@@ -56,11 +75,35 @@ func NewOrchestrator(cfg *Config) (*Orchestrator, error) {
 	pclient := podman.NewClient(&podman.Config{SocketPath: cfg.PodmanSocketPath})
 
 	// Create a consul client
-	cclient, err := api.NewClient(&api.Config{})
+	ccfg := cfg.ConsulConfig
+	if ccfg == nil {
+		ccfg = api.DefaultConfig()
+	}
+	cclient, err := api.NewClient(ccfg)
 	if err != nil {
 		return nil, fmt.Errorf("could not create consul client: %s", err)
 	}
 
+	// Get the ACL token, a token file takes precedence like in the consul client
+	token := ccfg.Token
+	if ccfg.TokenFile != "" {
+		buf, err := os.ReadFile(ccfg.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read consul token file: %s", err)
+		}
+		token = strings.TrimSpace(string(buf))
+	}
+
+	// Get the CA for envoy to verify the agent's gRPC endpoint
+	caPEM := string(ccfg.TLSConfig.CAPem)
+	if caPEM == "" && ccfg.TLSConfig.CAFile != "" {
+		buf, err := os.ReadFile(ccfg.TLSConfig.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read consul CA file: %s", err)
+		}
+		caPEM = string(buf)
+	}
+
 	// Get consul agent info
 	cinfo, err := cclient.Agent().Self()
 	if err != nil {
@@ -81,207 +124,231 @@ func NewOrchestrator(cfg *Config) (*Orchestrator, error) {
 	}
 
 	return &Orchestrator{
-		pclient:    pclient,
-		cclient:    cclient,
-		envoyImage: cfg.EnvoyImage,
-		grpcAddr:   addr,
-		grpcPort:   port,
-		grpcTLS:    tls,
+		pclient:      pclient,
+		cclient:      cclient,
+		podmanSocket: cfg.PodmanSocketPath,
+		envoyImage:   cfg.EnvoyImage,
+		grpcAddr:     addr,
+		grpcPort:     port,
+		grpcTLS:      tls,
+		consulToken:  token,
+		agentCAPEM:   caPEM,
+		datacenter:   info.Config.Datacenter,
 	}, nil
 }
 
 func (o *Orchestrator) Delete(ctx context.Context, nameOrID string) error {
-	// Try to get pod info from podman
-	pinfo, err := o.pclient.Pods().Inspect(ctx, nameOrID)
+	// Find all instances of the pod
+	instances, err := o.findInstances(ctx, nameOrID)
 	if err != nil {
-		return fmt.Errorf("could not get info on pod '%s': %s", nameOrID, err)
-	}
-
-	// Check if pod is mads managed.
-	// Simply the presence of the last-applied-configuration label is enough.
-	if _, ok := pinfo.Labels[lastAppliedLabel]; !ok {
-		return fmt.Errorf("pod '%s' is not managed by mads", nameOrID)
-	}
-
-	// Get a list of services to clean up
-	svcs := []string{}
-	if svcList, ok := pinfo.Labels[serviceIDsLabel]; ok {
-		svcs = strings.Split(svcList, ",")
+		return err
 	}
 
-	// Deregister services
-	for _, svc := range svcs {
-		err := o.cclient.Agent().ServiceDeregister(svc)
-		// If we get a 404 we might have already deregistered it in a previous run
-		// but it doesn't matter and we'll just continue.
-		if err != nil && !strings.Contains(err.Error(), "Unknown service ID") {
-			return fmt.Errorf("could not deregister consul service '%s': %s", svc, err)
+	// Fall back to looking the pod up directly, in case an ID was passed
+	if len(instances) < 1 {
+		pinfo, err := o.pclient.Pods().Inspect(ctx, nameOrID)
+		if err != nil {
+			return fmt.Errorf("could not get info on pod '%s': %s", nameOrID, err)
 		}
-	}
 
-	// Delete podman pod.
-	// We have confirmed that the pod has the last-applied-configuration label so we can just force delete it.
-	err = o.pclient.Pods().Delete(ctx, nameOrID, true)
-	if err != nil {
-		return fmt.Errorf("could not delete pod '%s': %s", nameOrID, err)
+		instances = append(instances, pinfo)
 	}
 
-	return nil
-}
+	for _, pinfo := range instances {
+		// Check if pod is mads managed.
+		// Simply the presence of the last-applied-configuration label is enough.
+		if _, ok := pinfo.Labels[lastAppliedLabel]; !ok {
+			return fmt.Errorf("pod '%s' is not managed by mads", nameOrID)
+		}
 
-func (o *Orchestrator) Apply(ctx context.Context, pod *entities.Pod) error {
-	// Create services
-	svcIDs := []string{}
-	for _, svc := range pod.Services {
-		id, ctr, err := o.createService(ctx, pod.Name, &svc)
+		// Deregister services and delete the pod
+		err := o.removeInstance(ctx, pinfo, nil)
 		if err != nil {
 			return err
 		}
+	}
 
-		// Add sidecar container to pod
-		if ctr != nil {
-			pod.Containers = append(pod.Containers, *ctr)
+	// Remove secrets created for the pod and volumes that should be deleted with it
+	if len(instances) > 0 {
+		name := instances[0].Labels[podNameLabel]
+		if name == "" {
+			name = instances[0].Name
 		}
+		o.pruneSecrets(ctx, name, nil)
 
-		// Add to list of services
-		svcIDs = append(svcIDs, id)
+		for _, pinfo := range instances {
+			err := o.removeVolumes(ctx, name, lastAppliedVolumes(pinfo), nil)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	// Add service IDs to pod labels
-	podLabels := map[string]string{
-		serviceIDsLabel: strings.Join(svcIDs, ","),
+	return nil
+}
+
+func (o *Orchestrator) Apply(ctx context.Context, pod *entities.Pod) error {
+	// Validate pod definition
+	err := validatePod(pod)
+	if err != nil {
+		return fmt.Errorf("invalid pod '%s': %s", pod.Name, err)
 	}
-	for k, v := range pod.Labels {
-		podLabels[k] = v
+
+	// Resolve values referenced by the pod
+	set, err := o.resolveSecrets(ctx, pod)
+	if err != nil {
+		return fmt.Errorf("could not resolve secrets for pod '%s': %s", pod.Name, err)
 	}
 
 	// Compute hash for current configuration
-	currHash, err := pod.Hash()
+	currHash, err := podHash(pod, set)
 	if err != nil {
 		return fmt.Errorf("could not compute hash for pod '%s': %s", pod.Name, err)
 	}
 
-	// Check if a pod with same name exists
-	exists, id, err := o.pclient.Pods().Exists(ctx, pod.Name)
+	// Find the currently running instance of the pod
+	current, err := o.findPod(ctx, pod.Name)
 	if err != nil {
-		return fmt.Errorf("could not check if pod exists: %s", err)
+		return err
 	}
 
-	// Check that applied hash is the same
-	if exists {
-		// Get pod info
-		info, err := o.pclient.Pods().Inspect(ctx, id)
+	// Pod doesn't exist yet so we simply create it
+	if current == nil {
+		inst, err := o.prepareInstance(ctx, pod, pod.Name, set, nil, false)
 		if err != nil {
 			return err
 		}
 
-		// Get last applied hash
-		lastHash, ok := info.Labels[lastAppliedLabel]
+		_, err = o.createInstance(ctx, pod, inst, currHash)
+		if err != nil {
+			return err
+		}
 
-		// No hash is present, we refuse to apply
-		if !ok {
-			return fmt.Errorf("pod '%s' has no mads label, will not apply", pod.Name)
+		err = o.startPod(ctx, inst.name)
+		if err != nil {
+			return err
 		}
 
-		// last applied hash is different from current configuration so we delete the pod
-		if lastHash != currHash {
-			err := o.pclient.Pods().Delete(ctx, id, true)
-			if err != nil {
-				return fmt.Errorf("could not delete old pod '%s': %s", id, err)
-			}
+		// Remove secrets left behind by a previous version of the pod
+		o.pruneSecrets(ctx, pod.Name, set.names())
 
-			// Set new state
-			exists = false
-			id = ""
-		}
+		return nil
 	}
 
-	// Create pod
-	if !exists {
-		// Save hash label
-		podLabels[lastAppliedLabel] = currHash
+	// No last applied configuration is present, we refuse to apply
+	if _, ok := current.Labels[lastAppliedLabel]; !ok {
+		return fmt.Errorf("pod '%s' has no mads label, will not apply", pod.Name)
+	}
 
-		// Create pod creation request
-		req := &pods.PodCreateRequest{
-			Name:   pod.Name,
-			Labels: podLabels,
-		}
+	// Get last applied hash
+	lastHash := appliedHash(current)
 
-		// Apply hosts
-		for host, ip := range pod.Hosts {
-			req.HostAdd = append(req.HostAdd, fmt.Sprintf("%s:%s", host, ip))
+	// Configuration is unchanged, we only make sure that services are
+	// registered and the pod is running
+	if lastHash == currHash {
+		ports := parseAdminPorts(current.Labels[adminPortsLabel])
+		_, _, err := o.registerServices(ctx, pod, current.Name, set, ports, false)
+		if err != nil {
+			return err
 		}
 
-		// Take various config from containers that needs to be set on pod level
-		for _, ctr := range pod.Containers {
-			// Apply port mappings from all containers
-			for _, mapping := range ctr.Ports {
-				req.PortMappings = append(req.PortMappings, pods.PodPortMapping{
-					HostIP:        mapping.HostIP,
-					HostPort:      mapping.HostPort,
-					ContainerPort: mapping.ContainerPort,
-					Protocol:      mapping.Protocol,
-				})
-			}
+		if current.State != pods.PodStateRunning {
+			return o.startPod(ctx, current.Name)
 		}
 
-		// Create pod
-		id, err = o.pclient.Pods().Create(ctx, req)
-		if err != nil {
-			return fmt.Errorf("could not create pod '%s': %s", pod.Name, err)
-		}
+		return nil
+	}
 
-		// Since we just created a new pod we need to create all of its containers
-		for _, ctr := range pod.Containers {
-			// Create container
-			ctrName := fmt.Sprintf("%s-%s", pod.Name, ctr.Name)
-			err := o.createContainer(ctx, ctrName, id, &ctr)
-			if err != nil {
-				// Delete pod to cleanup (best effort)
-				o.pclient.Pods().Delete(ctx, id, true)
+	// last applied hash is different from current configuration so we replace the pod
+	// Volumes of the current configuration, to remove those that are no longer used
+	oldVolumes := lastAppliedVolumes(current)
 
-				return fmt.Errorf("could not create container '%s' in pod '%s': %s", ctr.Name, pod.Name, err)
-			}
-		}
+	switch pod.UpdateStrategy.Type {
+	case entities.UpdateStrategyRolling:
+		err = o.replacePod(ctx, pod, current, currHash, set)
+	default:
+		err = o.recreatePod(ctx, pod, current, currHash, set)
 	}
+	if err != nil {
+		return err
+	}
+
+	// Remove secrets only the old pod used
+	o.pruneSecrets(ctx, pod.Name, set.names())
 
-	// Get pod info
-	info, err := o.pclient.Pods().Inspect(ctx, pod.Name)
+	// Remove volumes that were removed from the pod (best effort)
+	o.removeVolumes(ctx, pod.Name, oldVolumes, volumeNames(pod.Volumes))
+
+	return nil
+}
+
+// Get returns the last applied configuration of a pod.
+func (o *Orchestrator) Get(ctx context.Context, name string) (*entities.Snapshot, error) {
+	// Find the current instance of the pod
+	current, err := o.findPod(ctx, name)
 	if err != nil {
-		return fmt.Errorf("could not get info for pod '%s': %s", pod.Name, err)
+		return nil, err
+	}
+	if current == nil {
+		return nil, fmt.Errorf("pod '%s' not found", name)
 	}
 
-	// Start pod
-	if info.State != pods.PodStateRunning {
-		err := o.pclient.Pods().Start(ctx, pod.Name)
-		if err != nil && err != pods.ErrPodAlreadyStarted {
-			return err
-		}
+	// Get last applied configuration
+	lastApplied, ok := current.Labels[lastAppliedLabel]
+	if !ok {
+		return nil, fmt.Errorf("pod '%s' is not managed by mads", name)
 	}
 
-	return nil
+	// Decode it
+	snap, err := entities.DecodeSnapshot(lastApplied)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode last applied configuration of pod '%s': %s", name, err)
+	}
+
+	return snap, nil
 }
 
-func (o *Orchestrator) createService(ctx context.Context, podName string, svc *entities.Service) (string, *entities.Container, error) {
+func (o *Orchestrator) createService(ctx context.Context, pod *entities.Pod, instance string, svc *entities.Service, set secretSet, adminPort uint16) (string, *entities.Container, error) {
 	// Create a service registration
 	csvc := &api.AgentServiceRegistration{
-		ID:   fmt.Sprintf("mads-pod-%s-%s", podName, svc.Name),
+		ID:   fmt.Sprintf("mads-pod-%s-%s", instance, svc.Name),
 		Name: svc.Name,
 		Tags: svc.Tags,
 		Port: svc.Port,
 		Meta: map[string]string{
 			managedServiceMeta: "true",
-			servicePodNameMeta: podName,
+			servicePodNameMeta: pod.Name,
 		},
 		Connect: &api.AgentServiceConnect{
 			Native: svc.Connect.Native,
 		},
 	}
 
+	// Add container healthchecks as service checks
+	if checks := o.consulChecks(pod, instance, svc); len(checks) > 0 {
+		csvc.Checks = checks
+	}
+
 	// Add connect sidecar config if applicable
+	var metricsAddr string
+	var metricsPort uint16
 	if !csvc.Connect.Native && svc.Connect.SidecarService != nil {
 		csvc.Connect.SidecarService = &api.AgentServiceRegistration{}
 
+		// Add envoy metrics address to service meta so it can be discovered
+		if addr := svc.Connect.SidecarService.EnvoyPrometheusBindAddr; addr != "" {
+			host, port, err := parseBindAddr(addr)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid envoyPrometheusBindAddr: %s", err)
+			}
+			metricsAddr, metricsPort = host, port
+
+			csvc.Meta[metricsPortMeta] = strconv.Itoa(int(port))
+			if !net.ParseIP(host).IsUnspecified() {
+				csvc.Meta[metricsAddressMeta] = host
+			}
+		}
+
 		if svc.Connect.SidecarService.Proxy != nil {
 			// Setup proxy config
 			var proxyCfg *api.AgentServiceConnectProxyConfig
@@ -291,11 +358,24 @@ func (o *Orchestrator) createService(ctx context.Context, podName string, svc *e
 				proxyCfg = &api.AgentServiceConnectProxyConfig{Mode: api.ProxyModeTransparent}
 
 				for _, upstream := range svc.Connect.SidecarService.Proxy.Upstreams {
-					proxyCfg.Upstreams = append(proxyCfg.Upstreams, api.Upstream{
-						LocalBindAddress: upstream.LocalBindAddress,
-						LocalBindPort:    int(upstream.LocalBindPort),
-						DestinationName:  upstream.DestinationName,
-					})
+					u := api.Upstream{
+						DestinationType:      api.UpstreamDestType(upstream.DestinationType),
+						DestinationName:      upstream.DestinationName,
+						DestinationNamespace: upstream.DestinationNamespace,
+						DestinationPartition: upstream.DestinationPartition,
+						DestinationPeer:      upstream.DestinationPeer,
+						Datacenter:           upstream.Datacenter,
+						LocalBindAddress:     upstream.LocalBindAddress,
+						LocalBindPort:        int(upstream.LocalBindPort),
+						LocalBindSocketPath:  upstream.LocalBindSocketPath,
+						LocalBindSocketMode:  upstream.LocalBindSocketMode,
+						Config:               upstream.Config,
+					}
+					if upstream.MeshGateway != nil {
+						u.MeshGateway.Mode = api.MeshGatewayMode(upstream.MeshGateway.Mode)
+					}
+
+					proxyCfg.Upstreams = append(proxyCfg.Upstreams, u)
 				}
 			}
 
@@ -337,15 +417,42 @@ func (o *Orchestrator) createService(ctx context.Context, podName string, svc *e
 
 	// Check if service sidecar container needs to be created
 	if service != nil {
+		// The public port is assigned by consul when registering so it can't be
+		// taken into account when allocating the admin port
+		if service.Port == int(adminPort) {
+			return "", nil, fmt.Errorf("public port %d of sidecar '%s' is the same as its envoy admin port", service.Port, sidecarID)
+		}
+
+		// Get the address of the envoy admin API
+		adminAddress := svc.Connect.SidecarService.AdminBindAddress
+		if adminAddress == "" {
+			adminAddress = defaultAdminBindAddress
+		}
+
+		// With a bridged network the metrics address is bound on the host by
+		// the port mapping, envoy listens on all addresses inside the pod
+		metricsListenAddr := metricsAddr
+		if networkMode(pod) == entities.NetworkModeBridge {
+			metricsListenAddr = "0.0.0.0"
+		}
+
 		// Render envoy config for sidecar proxy
 		ecfg, err := envoy.TemplateConfig(&envoy.TemplateParams{
-			AdminAddress: "0.0.0.0",
-			AdminPort:    9100,
+			AdminAddress: adminAddress,
+			AdminPort:    adminPort,
 			ServiceName:  svc.Name,
 			ServiceID:    service.ID,
+			Datacenter:   firstNonEmpty(service.Datacenter, o.datacenter),
+			Namespace:    service.Namespace,
+			Partition:    service.Partition,
 			AgentAddress: o.grpcAddr,
 			AgentPort:    o.grpcPort,
 			AgentTLS:     o.grpcTLS,
+			AgentCAPEM:   o.agentCAPEM,
+			ConsulToken:  o.sidecarToken(svc, set),
+			// Envoy metrics listener
+			PrometheusAddress: metricsListenAddr,
+			PrometheusPort:    metricsPort,
 		})
 		if err != nil {
 			return "", nil, err
@@ -372,6 +479,19 @@ func (o *Orchestrator) createService(ctx context.Context, podName string, svc *e
 			}
 		}
 
+		// Publish envoy metrics port
+		if metricsPort > 0 {
+			mapping := entities.ContainerPortMapping{
+				HostPort:      metricsPort,
+				ContainerPort: metricsPort,
+				Protocol:      "tcp",
+			}
+			if !net.ParseIP(metricsAddr).IsUnspecified() {
+				mapping.HostIP = metricsAddr
+			}
+			ports = append(ports, mapping)
+		}
+
 		// Return a container that should be added to pod
 		return csvc.ID, &entities.Container{
 			Name:            fmt.Sprintf("%s-sidecar-proxy", svc.Name),
@@ -379,6 +499,7 @@ func (o *Orchestrator) createService(ctx context.Context, podName string, svc *e
 			ImagePullPolicy: images.PullPolicyMissing,
 			RestartPolicy:   containers.RestartPolicyAlways,
 			Args:            []string{"-c", "/etc/envoy/envoy.yml"},
+			Resources:       &sidecarResources,
 			Ports:           ports,
 			// Write the envoy bootstrap config file in the container
 			Files: []entities.ContainerFile{
@@ -394,22 +515,85 @@ func (o *Orchestrator) createService(ctx context.Context, podName string, svc *e
 	return csvc.ID, nil, nil
 }
 
-func (o *Orchestrator) createContainer(ctx context.Context, name, podID string, ctr *entities.Container) error {
-	// Get image
-	imageID, err := realizeImage(ctx, o.pclient, ctr.Image, ctr.ImagePullPolicy)
-	if err != nil {
-		return err
-	}
-
+func (o *Orchestrator) createContainer(ctx context.Context, name, podID, imageID string, ctr *entities.Container, initType string, set secretSet) error {
 	// Create container creation request
 	req := &containers.ContainerCreateRequest{
-		Name:    name,
-		Image:   imageID,
-		Pod:     podID,
-		Command: ctr.Args,
-		Env:     ctr.Env,
+		Name:       name,
+		Image:      imageID,
+		Pod:        podID,
+		Command:    ctr.Args,
+		Entrypoint: ctr.Entrypoint,
+		WorkDir:    ctr.WorkingDir,
+		Terminal:   ctr.TTY,
+		Stdin:      ctr.Stdin,
+		// Podman only allows whole seconds
+		StopTimeout: uint(time.Duration(ctr.StopTimeout).Seconds()),
+		Labels:      ctr.Labels,
+		Annotations: ctr.Annotations,
+		// Let podman restart the container according to its restart policy
+		RestartPolicy: ctr.RestartPolicy,
+		RestartTries:  ctr.RestartRetries,
+		// Add podman native healthcheck
+		HealthConfig: podmanHealthConfig(ctr.HealthCheck),
+		// Add resource limits
+		ResourceLimits: podmanResources(ctr.Resources),
+	}
+
+	// Make it an init container and mark it so it's not expected to keep running.
+	// Init containers run to completion so they are never restarted.
+	if initType != "" {
+		req.InitContainerType = initType
+		req.RestartPolicy = containers.RestartPolicyNo
+		req.RestartTries = 0
+		req.Labels = map[string]string{initContainerLabel: "true"}
+		for k, v := range ctr.Labels {
+			req.Labels[k] = v
+		}
 	}
 
+	// Set environment variables, referenced values are set from podman secrets
+	for k, v := range ctr.Env {
+		if v.ValueFrom != nil {
+			if req.SecretEnv == nil {
+				req.SecretEnv = map[string]string{}
+			}
+			req.SecretEnv[k] = set.get(v.ValueFrom)
+			continue
+		}
+
+		if req.Env == nil {
+			req.Env = map[string]string{}
+		}
+		req.Env[k] = v.Value
+	}
+
+	// Mount files with referenced content from podman secrets
+	files := []entities.ContainerFile{}
+	for _, f := range ctr.Files {
+		if f.ContentFrom == nil {
+			files = append(files, f)
+			continue
+		}
+
+		req.Secrets = append(req.Secrets, containers.Secret{
+			Source: set.get(f.ContentFrom),
+			Target: f.Destination,
+			Mode:   uint32(f.Mode),
+		})
+	}
+
+	// Parse stop signal
+	if ctr.StopSignal != "" {
+		sig, err := parseSignal(ctr.StopSignal)
+		if err != nil {
+			return err
+		}
+		req.StopSignal = sig
+	}
+
+	// Apply security context
+	applySecurityContext(req, ctr.SecurityContext)
+
 	// Apply mounts
 	for _, mount := range ctr.Mounts {
 		req.Mounts = append(req.Mounts, containers.ContainerMount{
@@ -421,17 +605,17 @@ func (o *Orchestrator) createContainer(ctx context.Context, name, podID string,
 	}
 
 	// Create container
-	err = o.pclient.Containers().Create(ctx, req)
+	err := o.pclient.Containers().Create(ctx, req)
 	if err != nil {
 		return err
 	}
 
 	// Write files to tar archive
-	if len(ctr.Files) > 0 {
+	if len(files) > 0 {
 		buf := &bytes.Buffer{}
 
 		// Write tar archive into buffer
-		err := writeTarArchive(ctx, buf, ctr.Files)
+		err := writeTarArchive(ctx, buf, files)
 		if err != nil {
 			return err
 		}
@@ -520,6 +704,16 @@ func realizeImage(ctx context.Context, client *podman.Client, rawImage, pullPoli
 	return info.Id, nil
 }
 
+// firstNonEmpty returns the first of the values that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 func findGRPCAddrPort(info *consulInfo) (string, uint16, bool, error) {
 	// First look in TLS addrs
 	for _, addr := range info.DebugConfig.GRPCTLSAddrs {
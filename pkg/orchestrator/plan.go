@@ -0,0 +1,188 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/arnarg/mads/pkg/diff"
+	"github.com/arnarg/mads/pkg/entities"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	PlanActionCreate     = "create"
+	PlanActionReplace    = "replace"
+	PlanActionUpdate     = "update"
+	PlanActionUnchanged  = "unchanged"
+	PlanActionRegister   = "register"
+	PlanActionDeregister = "deregister"
+)
+
+// Plan describes what applying a pod would change.
+type Plan struct {
+	Pod      string
+	Instance string
+	Action   string
+	Strategy string
+	Diff     string
+	Services []PlanService
+	Sidecars []PlanSidecar
+}
+
+type PlanService struct {
+	ID     string
+	Name   string
+	Action string
+}
+
+type PlanSidecar struct {
+	Name    string
+	Service string
+	Action  string
+}
+
+// Plan computes what applying a pod would change without changing anything.
+func (o *Orchestrator) Plan(ctx context.Context, pod *entities.Pod) (*Plan, error) {
+	// Validate pod definition
+	err := validatePod(pod)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod '%s': %s", pod.Name, err)
+	}
+
+	// Compute hash for current configuration
+	set, err := o.resolveSecrets(ctx, pod)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve secrets for pod '%s': %s", pod.Name, err)
+	}
+	currHash, err := podHash(pod, set)
+	if err != nil {
+		return nil, fmt.Errorf("could not compute hash for pod '%s': %s", pod.Name, err)
+	}
+
+	// Find the currently running instance of the pod
+	current, err := o.findPod(ctx, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Pod:      pod.Name,
+		Instance: pod.Name,
+		Action:   PlanActionCreate,
+		Strategy: pod.UpdateStrategy.Type,
+	}
+
+	// Figure out what happens to the pod itself
+	var last *entities.Pod
+	oldIDs := []string{}
+	if current != nil {
+		lastApplied, ok := current.Labels[lastAppliedLabel]
+		if !ok {
+			return nil, fmt.Errorf("pod '%s' has no mads label, will not apply", pod.Name)
+		}
+
+		snap, err := entities.DecodeSnapshot(lastApplied)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode last applied configuration of pod '%s': %s", pod.Name, err)
+		}
+		last = snap.Pod
+		lastHash := appliedHash(current)
+
+		oldIDs = splitServiceIDs(current)
+		plan.Instance = current.Name
+
+		if lastHash == currHash {
+			plan.Action = PlanActionUnchanged
+		} else {
+			plan.Action = PlanActionReplace
+
+			if pod.UpdateStrategy.Type == entities.UpdateStrategyRolling {
+				plan.Instance = otherInstanceName(pod.Name, current.Name)
+			}
+		}
+	}
+
+	// Compute diff between last applied and new configuration
+	plan.Diff, err = podDiff(last, pod)
+	if err != nil {
+		return nil, err
+	}
+
+	// Figure out what happens to services and their sidecars
+	newIDs := []string{}
+	for _, svc := range pod.Services {
+		id := fmt.Sprintf("mads-pod-%s-%s", plan.Instance, svc.Name)
+		newIDs = append(newIDs, id)
+
+		action := PlanActionRegister
+		if contains(oldIDs, id) {
+			action = PlanActionUpdate
+			if plan.Action == PlanActionUnchanged {
+				action = PlanActionUnchanged
+			}
+		}
+		plan.Services = append(plan.Services, PlanService{ID: id, Name: svc.Name, Action: action})
+
+		if !svc.Connect.Native && svc.Connect.SidecarService != nil {
+			action := plan.Action
+			if plan.Action == PlanActionReplace && last != nil && !hasSidecar(last, svc.Name) {
+				action = PlanActionCreate
+			}
+			plan.Sidecars = append(plan.Sidecars, PlanSidecar{
+				Name:    fmt.Sprintf("%s-sidecar-proxy", svc.Name),
+				Service: svc.Name,
+				Action:  action,
+			})
+		}
+	}
+
+	// Services that are no longer used get deregistered
+	for _, id := range excludeIDs(oldIDs, newIDs) {
+		plan.Services = append(plan.Services, PlanService{ID: id, Action: PlanActionDeregister})
+	}
+
+	return plan, nil
+}
+
+// podDiff returns a diff between the YAML representation of two pods.
+// A nil pod is treated as empty.
+func podDiff(a, b *entities.Pod) (string, error) {
+	aYAML, err := podYAML(a)
+	if err != nil {
+		return "", err
+	}
+
+	bYAML, err := podYAML(b)
+	if err != nil {
+		return "", err
+	}
+
+	return diff.Unified("last applied", "new", aYAML, bYAML), nil
+}
+
+func podYAML(pod *entities.Pod) (string, error) {
+	if pod == nil {
+		return "", nil
+	}
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+
+	err := enc.Encode(pod)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func hasSidecar(pod *entities.Pod, svcName string) bool {
+	for _, svc := range pod.Services {
+		if svc.Name == svcName {
+			return !svc.Connect.Native && svc.Connect.SidecarService != nil
+		}
+	}
+	return false
+}
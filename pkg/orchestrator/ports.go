@@ -0,0 +1,380 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// Envoy admin ports are allocated from this port upwards
+	adminPortStart uint16 = 19000
+	// The envoy admin API is only reachable from within the pod by default
+	defaultAdminBindAddress = "127.0.0.1"
+)
+
+// adminPorts maps service names to the envoy admin port of their sidecar.
+type adminPorts map[string]uint16
+
+// parseAdminPorts parses admin ports from a pod label in the form
+// "svc1=19000,svc2=19001". Invalid entries are ignored.
+func parseAdminPorts(label string) adminPorts {
+	ports := adminPorts{}
+
+	for _, entry := range strings.Split(label, ",") {
+		name, portStr, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			continue
+		}
+
+		ports[name] = uint16(port)
+	}
+
+	return ports
+}
+
+// String returns the admin ports in the pod label format.
+func (p adminPorts) String() string {
+	entries := []string{}
+	for name, port := range p {
+		entries = append(entries, fmt.Sprintf("%s=%d", name, port))
+	}
+	sort.Strings(entries)
+
+	return strings.Join(entries, ",")
+}
+
+// hostPort is a port mapped on the host.
+// An empty or unspecified IP means the port is mapped on all addresses.
+type hostPort struct {
+	ip    string
+	port  uint16
+	proto string
+}
+
+// newHostPort returns a host port, podman defaults to tcp when no protocol is set.
+func newHostPort(ip string, port uint16, proto string) hostPort {
+	if proto == "" {
+		proto = "tcp"
+	}
+	return hostPort{ip: ip, port: port, proto: strings.ToLower(proto)}
+}
+
+// conflicts checks if two host ports can't be mapped at the same time.
+func (p hostPort) conflicts(other hostPort) bool {
+	if p.port != other.port || p.proto != other.proto {
+		return false
+	}
+	return isAnyAddress(p.ip) || isAnyAddress(other.ip) || net.ParseIP(p.ip).Equal(net.ParseIP(other.ip))
+}
+
+// String returns the host port in the form "ip:port/protocol".
+func (p hostPort) String() string {
+	return fmt.Sprintf("%s/%s", net.JoinHostPort(p.ip, strconv.Itoa(int(p.port))), p.proto)
+}
+
+func isAnyAddress(ip string) bool {
+	return ip == "" || net.ParseIP(ip).IsUnspecified()
+}
+
+// parseHostPorts parses host ports from a pod label in the form
+// "ip:port/protocol,...". Invalid entries are ignored.
+func parseHostPorts(label string) []hostPort {
+	ports := []hostPort{}
+
+	for _, entry := range strings.Split(label, ",") {
+		addr, proto, ok := strings.Cut(entry, "/")
+		if !ok {
+			continue
+		}
+
+		ip, portStr, err := net.SplitHostPort(addr)
+		if err != nil {
+			continue
+		}
+
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			continue
+		}
+
+		ports = append(ports, newHostPort(ip, uint16(port), proto))
+	}
+
+	return ports
+}
+
+// formatHostPorts returns host ports in the pod label format.
+func formatHostPorts(ports []hostPort) string {
+	entries := []string{}
+	for _, p := range ports {
+		entries = append(entries, p.String())
+	}
+	return strings.Join(entries, ",")
+}
+
+// usedHostPort is a host port used by another mads pod.
+type usedHostPort struct {
+	hostPort
+	pod string
+}
+
+// usedPorts are the ports used by other mads pods.
+type usedPorts struct {
+	hostPorts []usedHostPort
+	// Envoy admin ports of sidecars in pods with the host network, those of
+	// other pods are only reachable in their own network namespace
+	adminPorts map[uint16]bool
+	// Public ports of all sidecar proxies registered in the consul agent,
+	// including the ones of the pod itself
+	sidecarPorts map[uint16]bool
+}
+
+// usedPorts returns the host ports and envoy admin ports used by all mads pods,
+// except for the instances of the pod with the given name.
+func (o *Orchestrator) usedPorts(ctx context.Context, podName string) (*usedPorts, error) {
+	used := &usedPorts{
+		adminPorts:   map[uint16]bool{},
+		sidecarPorts: map[uint16]bool{},
+	}
+
+	// Sidecar public ports are assigned by consul when the sidecar is registered
+	proxies, err := o.cclient.Agent().ServicesWithFilter(fmt.Sprintf("Kind == \"%s\"", api.ServiceKindConnectProxy))
+	if err != nil {
+		return nil, fmt.Errorf("could not list consul services: %s", err)
+	}
+	for _, proxy := range proxies {
+		used.sidecarPorts[uint16(proxy.Port)] = true
+	}
+
+	// Find all pods with the mads label
+	list, err := o.pclient.Pods().List(ctx, map[string][]string{
+		"label": {lastAppliedLabel},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list pods: %s", err)
+	}
+
+	for _, entry := range list {
+		// Pods created by older versions of mads don't have the pod name label
+		name := entry.Labels[podNameLabel]
+		if name == "" {
+			name = entry.Name
+		}
+		if name == podName {
+			continue
+		}
+
+		// Admin ports are recorded in a label
+		if label, ok := entry.Labels[adminPortsLabel]; ok {
+			snap, err := entities.DecodeSnapshot(entry.Labels[lastAppliedLabel])
+			if err == nil && snap.Pod != nil && networkMode(snap.Pod) == entities.NetworkModeHost {
+				for _, port := range parseAdminPorts(label) {
+					used.adminPorts[port] = true
+				}
+			}
+		}
+
+		// Host ports are recorded in a label as well
+		if label, ok := entry.Labels[hostPortsLabel]; ok {
+			for _, p := range parseHostPorts(label) {
+				used.hostPorts = append(used.hostPorts, usedHostPort{p, name})
+			}
+			continue
+		}
+
+		// Pods created by older versions of mads don't have it and need to be inspected
+		info, err := o.pclient.Pods().Inspect(ctx, entry.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		// Bindings are keyed by container port and protocol, e.g. "80/tcp"
+		for key, bindings := range info.InfraConfig.PortBindings {
+			_, proto, _ := strings.Cut(key, "/")
+			for _, binding := range bindings {
+				port, err := strconv.ParseUint(binding.HostPort, 10, 16)
+				if err != nil {
+					continue
+				}
+				p := newHostPort(binding.HostIp, uint16(port), proto)
+				used.hostPorts = append(used.hostPorts, usedHostPort{p, name})
+			}
+		}
+	}
+
+	return used, nil
+}
+
+// allocateAdminPorts allocates an envoy admin port for every sidecar of a pod.
+// Ports allocated to the current instance are kept so they stay the same across applies.
+func allocateAdminPorts(pod *entities.Pod, current adminPorts, used *usedPorts) adminPorts {
+	taken := map[uint16]bool{}
+
+	// Admin ports are in the network namespace of the pod, they can only
+	// conflict with other pods when using the host network
+	if networkMode(pod) == entities.NetworkModeHost {
+		for port := range used.adminPorts {
+			taken[port] = true
+		}
+		for _, p := range used.hostPorts {
+			taken[p.port] = true
+		}
+	}
+
+	// Ports that containers in the pod listen on can't be used either
+	for _, ctr := range pod.Containers {
+		for _, mapping := range ctr.Ports {
+			taken[mapping.ContainerPort] = true
+		}
+	}
+
+	// Neither can ports that sidecars listen on, public ports of the sidecars of
+	// the pod are only known once they are registered
+	for port := range used.sidecarPorts {
+		taken[port] = true
+	}
+	for _, svc := range sidecarServices(pod) {
+		if _, port, err := parseBindAddr(svc.Connect.SidecarService.EnvoyPrometheusBindAddr); err == nil {
+			taken[port] = true
+		}
+
+		if svc.Connect.SidecarService.Proxy == nil {
+			continue
+		}
+		for _, upstream := range svc.Connect.SidecarService.Proxy.Upstreams {
+			taken[upstream.LocalBindPort] = true
+		}
+		for _, expose := range svc.Connect.SidecarService.Proxy.Expose.Paths {
+			taken[expose.ListenerPort] = true
+		}
+	}
+
+	ports := adminPorts{}
+
+	// Keep previous allocations
+	for _, svc := range sidecarServices(pod) {
+		if port, ok := current[svc.Name]; ok && !taken[port] {
+			ports[svc.Name] = port
+			taken[port] = true
+		}
+	}
+
+	// Allocate the next free port for the rest
+	next := adminPortStart
+	for _, svc := range sidecarServices(pod) {
+		if _, ok := ports[svc.Name]; ok {
+			continue
+		}
+
+		for taken[next] {
+			next++
+		}
+
+		ports[svc.Name] = next
+		taken[next] = true
+	}
+
+	return ports
+}
+
+// sidecarServices returns the services of a pod that get a sidecar proxy.
+func sidecarServices(pod *entities.Pod) []entities.Service {
+	svcs := []entities.Service{}
+	for _, svc := range pod.Services {
+		if !svc.Connect.Native && svc.Connect.SidecarService != nil {
+			svcs = append(svcs, svc)
+		}
+	}
+	return svcs
+}
+
+// podHostPorts returns the host ports that the containers of a pod map.
+func podHostPorts(pod *entities.Pod, ctrs []entities.Container) []hostPort {
+	ports := []hostPort{}
+
+	// Ports are only mapped with a bridged network
+	if networkMode(pod) != entities.NetworkModeBridge {
+		return ports
+	}
+
+	for _, ctr := range ctrs {
+		for _, mapping := range ctr.Ports {
+			if mapping.HostPort == 0 {
+				continue
+			}
+			ports = append(ports, newHostPort(mapping.HostIP, mapping.HostPort, mapping.Protocol))
+		}
+	}
+
+	return ports
+}
+
+// checkHostPorts makes sure that the host ports that the containers of a pod
+// map aren't used by other mads pods or twice in the same pod.
+func checkHostPorts(pod *entities.Pod, ctrs []entities.Container, used *usedPorts) error {
+	// Ports are only mapped with a bridged network
+	if networkMode(pod) != entities.NetworkModeBridge {
+		return nil
+	}
+
+	type mappedPort struct {
+		hostPort
+		ctr string
+	}
+	mapped := []mappedPort{}
+
+	for _, ctr := range ctrs {
+		for _, mapping := range ctr.Ports {
+			if mapping.HostPort == 0 {
+				continue
+			}
+			p := newHostPort(mapping.HostIP, mapping.HostPort, mapping.Protocol)
+
+			for _, u := range used.hostPorts {
+				if p.conflicts(u.hostPort) {
+					return fmt.Errorf("host port %s of container '%s' is already used by pod '%s'", p, ctr.Name, u.pod)
+				}
+			}
+			for _, m := range mapped {
+				if p.conflicts(m.hostPort) {
+					return fmt.Errorf("host port %s is mapped by both container '%s' and '%s'", p, m.ctr, ctr.Name)
+				}
+			}
+
+			mapped = append(mapped, mappedPort{p, ctr.Name})
+		}
+	}
+
+	return nil
+}
+
+// parseBindAddr parses an address in the form "host:port" where host is an IP address.
+func parseBindAddr(addr string) (string, uint16, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if net.ParseIP(host) == nil {
+		return "", 0, fmt.Errorf("'%s' is not an IP address", host)
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || port == 0 {
+		return "", 0, fmt.Errorf("invalid port '%s'", portStr)
+	}
+
+	return host, uint16(port), nil
+}
@@ -0,0 +1,94 @@
+package orchestrator
+
+import (
+	"fmt"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/arnarg/mads/pkg/podman/containers"
+)
+
+// Default CPU period used when limiting CPUs, same as podman.
+const defaultCPUPeriod = 100000
+
+// Default resource limits for envoy sidecar proxies
+var sidecarResources = entities.Resources{
+	Memory:    256 << 20,
+	CPUs:      1,
+	PidsLimit: 1024,
+}
+
+// podmanResources converts resource limits into podman's resource limits.
+func podmanResources(r *entities.Resources) *containers.LinuxResources {
+	if r == nil {
+		return nil
+	}
+
+	res := &containers.LinuxResources{}
+
+	// Memory limits
+	if r.Memory != 0 || r.MemoryReservation != 0 || r.MemorySwap != 0 {
+		res.Memory = &containers.LinuxMemory{}
+		if r.Memory != 0 {
+			limit := int64(r.Memory)
+			res.Memory.Limit = &limit
+		}
+		if r.MemoryReservation != 0 {
+			reservation := int64(r.MemoryReservation)
+			res.Memory.Reservation = &reservation
+		}
+		if r.MemorySwap != 0 {
+			swap := int64(r.MemorySwap)
+			res.Memory.Swap = &swap
+		}
+	}
+
+	// CPU limits
+	if r.CPUs != 0 || r.CPUQuota != 0 || r.CPUPeriod != 0 || r.CPUShares != 0 || r.Cpuset != "" {
+		res.CPU = &containers.LinuxCPU{Cpus: r.Cpuset}
+
+		period := r.CPUPeriod
+		if (r.CPUs != 0 || r.CPUQuota != 0) && period == 0 {
+			period = defaultCPUPeriod
+		}
+		if period != 0 {
+			res.CPU.Period = &period
+		}
+
+		quota := r.CPUQuota
+		if r.CPUs != 0 {
+			quota = int64(r.CPUs * float64(period))
+		}
+		if quota != 0 {
+			res.CPU.Quota = &quota
+		}
+
+		if r.CPUShares != 0 {
+			shares := r.CPUShares
+			res.CPU.Shares = &shares
+		}
+	}
+
+	// Pids limit
+	if r.PidsLimit != 0 {
+		res.Pids = &containers.LinuxPids{Limit: r.PidsLimit}
+	}
+
+	return res
+}
+
+func validateResources(r *entities.Resources) error {
+	if r.CPUs < 0 {
+		return fmt.Errorf("cpus can't be negative")
+	}
+	if r.CPUs != 0 && r.CPUQuota != 0 {
+		return fmt.Errorf("only one of cpus and cpuQuota can be set")
+	}
+	if r.Memory < 0 || r.MemoryReservation < 0 {
+		return fmt.Errorf("memory limits can't be negative")
+	}
+	if r.MemorySwap > 0 && r.MemorySwap < r.Memory {
+		return fmt.Errorf("memorySwap has to be larger than memory")
+	}
+
+	return nil
+}
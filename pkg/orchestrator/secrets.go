@@ -0,0 +1,256 @@
+package orchestrator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/arnarg/mads/pkg/podman/secrets"
+	"github.com/hashicorp/consul/api"
+)
+
+// resolvedSecret is a value referenced by a pod and the podman secret it's delivered with.
+type resolvedSecret struct {
+	name   string
+	digest string
+	// Data is only set for values that mads has to read itself
+	data []byte
+	// Inline values are only rendered into configuration mads generates,
+	// such as sidecar ACL tokens, and don't need a podman secret
+	inline bool
+}
+
+// secretSet maps the identifier of every value source in a pod to its resolved secret.
+type secretSet map[string]*resolvedSecret
+
+// get returns the podman secret name for a value source.
+func (s secretSet) get(src *entities.ValueSource) string {
+	if secret, ok := s[src.String()]; ok {
+		return secret.name
+	}
+	return ""
+}
+
+// value returns the value of a value source that mads has read itself.
+func (s secretSet) value(src *entities.ValueSource) string {
+	if secret, ok := s[src.String()]; ok {
+		return string(secret.data)
+	}
+	return ""
+}
+
+// names returns the podman secret names of all secrets in the set.
+func (s secretSet) names() []string {
+	names := []string{}
+	for _, secret := range s {
+		if !secret.inline {
+			names = append(names, secret.name)
+		}
+	}
+	return names
+}
+
+// valueSources returns all value sources referenced by containers in a pod.
+func valueSources(pod *entities.Pod) []*entities.ValueSource {
+	srcs := []*entities.ValueSource{}
+	for _, ctr := range userContainers(pod) {
+		for _, v := range ctr.Env {
+			if v.ValueFrom != nil {
+				srcs = append(srcs, v.ValueFrom)
+			}
+		}
+		for _, f := range ctr.Files {
+			if f.ContentFrom != nil {
+				srcs = append(srcs, f.ContentFrom)
+			}
+		}
+	}
+	return srcs
+}
+
+// tokenSources returns the value sources of ACL tokens of sidecar proxies in a pod.
+func tokenSources(pod *entities.Pod) []*entities.ValueSource {
+	srcs := []*entities.ValueSource{}
+	for _, svc := range pod.Services {
+		if svc.Connect.SidecarService != nil && svc.Connect.SidecarService.TokenFrom != nil {
+			srcs = append(srcs, svc.Connect.SidecarService.TokenFrom)
+		}
+	}
+	return srcs
+}
+
+// resolveSecrets reads all values referenced by a pod.
+// Existing podman secrets are only looked up as podman never returns their data.
+func (o *Orchestrator) resolveSecrets(ctx context.Context, pod *entities.Pod) (secretSet, error) {
+	set := secretSet{}
+
+	// Values used by containers need a podman secret
+	inline := map[string]bool{}
+	for _, src := range tokenSources(pod) {
+		inline[src.String()] = true
+	}
+	for _, src := range valueSources(pod) {
+		inline[src.String()] = false
+	}
+
+	for _, src := range append(valueSources(pod), tokenSources(pod)...) {
+		key := src.String()
+		if _, ok := set[key]; ok {
+			continue
+		}
+
+		// Existing podman secret, it changes when it's recreated or updated
+		if src.PodmanSecret != "" {
+			info, err := o.pclient.Secrets().Inspect(ctx, src.PodmanSecret)
+			if err != nil {
+				return nil, fmt.Errorf("could not find podman secret '%s': %s", src.PodmanSecret, err)
+			}
+
+			set[key] = &resolvedSecret{
+				name:   src.PodmanSecret,
+				digest: digest([]byte(info.ID + info.UpdatedAt)),
+			}
+			continue
+		}
+
+		// Read value from consul or a file
+		var data []byte
+		switch {
+		case src.ConsulKV != "":
+			pair, _, err := o.cclient.KV().Get(src.ConsulKV, (&api.QueryOptions{}).WithContext(ctx))
+			if err != nil {
+				return nil, fmt.Errorf("could not read consul key '%s': %s", src.ConsulKV, err)
+			}
+			if pair == nil {
+				return nil, fmt.Errorf("consul key '%s' does not exist", src.ConsulKV)
+			}
+			data = pair.Value
+		default:
+			buf, err := os.ReadFile(src.File)
+			if err != nil {
+				return nil, fmt.Errorf("could not read file '%s': %s", src.File, err)
+			}
+			data = buf
+		}
+
+		// Secrets are named after their content so a changed value gets a new
+		// secret and the current pod keeps using the old one until it's replaced
+		d := digest(data)
+		set[key] = &resolvedSecret{
+			name:   fmt.Sprintf("mads-%s-%s", pod.Name, d[:16]),
+			digest: d,
+			data:   data,
+			inline: inline[key],
+		}
+	}
+
+	return set, nil
+}
+
+// podHash computes the hash of a pod's configuration including the digests of
+// all values it references, so the hash changes when a referenced value changes.
+// Only digests are used and the values themselves never end up in pod labels.
+func podHash(pod *entities.Pod, set secretSet) (string, error) {
+	hash, err := pod.Hash()
+	if err != nil {
+		return "", err
+	}
+
+	// Pods without references keep the plain hash
+	if len(set) < 1 {
+		return hash, nil
+	}
+
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	h.Write([]byte(hash))
+	for _, key := range keys {
+		fmt.Fprintf(h, "\n%s=%s", key, set[key].digest)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sidecarToken returns the ACL token the sidecar proxy of a service should use.
+func (o *Orchestrator) sidecarToken(svc *entities.Service, set secretSet) string {
+	if svc.Connect.SidecarService != nil && svc.Connect.SidecarService.TokenFrom != nil {
+		return strings.TrimSpace(set.value(svc.Connect.SidecarService.TokenFrom))
+	}
+	return o.consulToken
+}
+
+// createSecrets creates podman secrets for all resolved values that don't have one yet.
+func (o *Orchestrator) createSecrets(ctx context.Context, pod *entities.Pod, set secretSet) error {
+	for _, secret := range set {
+		if secret.data == nil || secret.inline {
+			continue
+		}
+
+		exists, err := o.pclient.Secrets().Exists(ctx, secret.name)
+		if err != nil {
+			return fmt.Errorf("could not check if secret '%s' exists: %s", secret.name, err)
+		}
+		if exists {
+			continue
+		}
+
+		_, err = o.pclient.Secrets().Create(ctx, &secrets.SecretCreateRequest{
+			Name: secret.name,
+			Labels: map[string]string{
+				podNameLabel: pod.Name,
+			},
+			Data: secret.data,
+		})
+		if err != nil {
+			return fmt.Errorf("could not create secret '%s': %s", secret.name, err)
+		}
+	}
+
+	return nil
+}
+
+// pruneSecrets deletes podman secrets that mads created for a pod, except
+// the ones in keep (best effort).
+func (o *Orchestrator) pruneSecrets(ctx context.Context, podName string, keep []string) {
+	list, err := o.pclient.Secrets().List(ctx, nil)
+	if err != nil {
+		return
+	}
+
+	for _, secret := range list {
+		if secret.Spec.Labels[podNameLabel] != podName || contains(keep, secret.Spec.Name) {
+			continue
+		}
+
+		o.pclient.Secrets().Delete(ctx, secret.ID)
+	}
+}
+
+func validateValueSource(src *entities.ValueSource) error {
+	set := 0
+	for _, v := range []string{src.PodmanSecret, src.ConsulKV, src.File} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of podmanSecret, consulKV and file has to be set")
+	}
+
+	return nil
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,128 @@
+package orchestrator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/arnarg/mads/pkg/podman/containers"
+)
+
+// applySecurityContext sets the security settings of a container creation request.
+func applySecurityContext(req *containers.ContainerCreateRequest, sc *entities.SecurityContext) {
+	if sc == nil {
+		return
+	}
+
+	// Run as user and group
+	if sc.RunAsUser != nil {
+		req.User = fmt.Sprintf("%d", *sc.RunAsUser)
+		if sc.RunAsGroup != nil {
+			req.User = fmt.Sprintf("%s:%d", req.User, *sc.RunAsGroup)
+		}
+	}
+
+	// Capabilities
+	if sc.Capabilities != nil {
+		req.CapAdd = normalizeCapabilities(sc.Capabilities.Add)
+		req.CapDrop = normalizeCapabilities(sc.Capabilities.Drop)
+	}
+
+	req.ReadOnlyFilesystem = sc.ReadOnlyRootFilesystem
+	req.Privileged = sc.Privileged
+	req.NoNewPrivileges = sc.NoNewPrivileges
+	req.SeccompProfilePath = sc.SeccompProfile
+
+	// SELinux labels
+	if opts := sc.SELinuxOptions; opts != nil {
+		if opts.Disable {
+			req.SelinuxOpts = append(req.SelinuxOpts, "disable")
+		}
+		if opts.User != "" {
+			req.SelinuxOpts = append(req.SelinuxOpts, "user:"+opts.User)
+		}
+		if opts.Role != "" {
+			req.SelinuxOpts = append(req.SelinuxOpts, "role:"+opts.Role)
+		}
+		if opts.Type != "" {
+			req.SelinuxOpts = append(req.SelinuxOpts, "type:"+opts.Type)
+		}
+		if opts.Level != "" {
+			req.SelinuxOpts = append(req.SelinuxOpts, "level:"+opts.Level)
+		}
+	}
+}
+
+// normalizeCapabilities upper cases capability names and strips the "CAP_" prefix.
+func normalizeCapabilities(caps []string) []string {
+	var norm []string
+	for _, c := range caps {
+		norm = append(norm, strings.TrimPrefix(strings.ToUpper(c), "CAP_"))
+	}
+	return norm
+}
+
+// podUserNS returns the user namespace of the pod from the security context of its containers.
+func podUserNS(ctrs []entities.Container) *containers.Namespace {
+	for _, ctr := range ctrs {
+		if ctr.SecurityContext != nil && ctr.SecurityContext.UserNS != "" {
+			return parseUserNS(ctr.SecurityContext.UserNS)
+		}
+	}
+	return nil
+}
+
+// parseUserNS parses a user namespace mode in the same format as podman's --userns flag.
+func parseUserNS(mode string) *containers.Namespace {
+	name, value, _ := strings.Cut(mode, ":")
+
+	// Podman calls namespaces joined by path "path" in the API
+	if name == "ns" {
+		name = "path"
+	}
+
+	return &containers.Namespace{NSMode: name, Value: value}
+}
+
+func validateSecurityContext(sc *entities.SecurityContext) error {
+	if sc.RunAsGroup != nil && sc.RunAsUser == nil {
+		return fmt.Errorf("runAsGroup can only be used together with runAsUser")
+	}
+	if sc.RunAsUser != nil && *sc.RunAsUser < 0 {
+		return fmt.Errorf("runAsUser can't be negative")
+	}
+	if sc.RunAsGroup != nil && *sc.RunAsGroup < 0 {
+		return fmt.Errorf("runAsGroup can't be negative")
+	}
+
+	if sc.SeccompProfile != "" && sc.SeccompProfile != "unconfined" && !filepath.IsAbs(sc.SeccompProfile) {
+		return fmt.Errorf("seccompProfile has to be an absolute path or 'unconfined'")
+	}
+
+	if sc.UserNS != "" {
+		name, _, _ := strings.Cut(sc.UserNS, ":")
+		switch name {
+		case "auto", "host", "keep-id", "nomap", "ns", "private":
+		default:
+			return fmt.Errorf("unknown userns mode '%s'", sc.UserNS)
+		}
+	}
+
+	return nil
+}
+
+// validatePodUserNS checks that all containers in a pod agree on the user namespace.
+func validatePodUserNS(pod *entities.Pod) error {
+	userns := ""
+	for _, ctr := range userContainers(pod) {
+		if ctr.SecurityContext == nil || ctr.SecurityContext.UserNS == "" {
+			continue
+		}
+		if userns != "" && ctr.SecurityContext.UserNS != userns {
+			return fmt.Errorf("all containers have to use the same userns, got '%s' and '%s'", userns, ctr.SecurityContext.UserNS)
+		}
+		userns = ctr.SecurityContext.UserNS
+	}
+	return nil
+}
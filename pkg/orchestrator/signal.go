@@ -0,0 +1,75 @@
+package orchestrator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Signal numbers on Linux, which is where podman runs, independent of
+// the platform mads itself was built for.
+var signals = map[string]int{
+	"HUP":    1,
+	"INT":    2,
+	"QUIT":   3,
+	"ILL":    4,
+	"TRAP":   5,
+	"ABRT":   6,
+	"BUS":    7,
+	"FPE":    8,
+	"KILL":   9,
+	"USR1":   10,
+	"SEGV":   11,
+	"USR2":   12,
+	"PIPE":   13,
+	"ALRM":   14,
+	"TERM":   15,
+	"STKFLT": 16,
+	"CHLD":   17,
+	"CONT":   18,
+	"STOP":   19,
+	"TSTP":   20,
+	"TTIN":   21,
+	"TTOU":   22,
+	"URG":    23,
+	"XCPU":   24,
+	"XFSZ":   25,
+	"VTALRM": 26,
+	"PROF":   27,
+	"WINCH":  28,
+	"IO":     29,
+	"PWR":    30,
+	"SYS":    31,
+}
+
+// Range of real-time signals on Linux
+const (
+	sigRTMin = 34
+	sigRTMax = 64
+)
+
+// parseSignal parses a signal name, with or without the "SIG" prefix, or number.
+func parseSignal(s string) (int, error) {
+	// Signal number
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 || n > sigRTMax {
+			return 0, fmt.Errorf("invalid signal number %d", n)
+		}
+		return n, nil
+	}
+
+	name := strings.TrimPrefix(strings.ToUpper(s), "SIG")
+	if n, ok := signals[name]; ok {
+		return n, nil
+	}
+
+	// Real-time signals, e.g. RTMIN+3
+	if strings.HasPrefix(name, "RTMIN+") {
+		offset, err := strconv.Atoi(strings.TrimPrefix(name, "RTMIN+"))
+		if err == nil && offset >= 0 && sigRTMin+offset <= sigRTMax {
+			return sigRTMin + offset, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown signal '%s'", s)
+}
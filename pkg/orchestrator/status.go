@@ -0,0 +1,94 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ServiceHealthMissing is reported for services that are not registered in consul.
+const ServiceHealthMissing = "missing"
+
+type PodStatus struct {
+	Name       string            `yaml:"name" json:"name"`
+	Instance   string            `yaml:"instance" json:"instance"`
+	State      string            `yaml:"state" json:"state"`
+	Containers []ContainerStatus `yaml:"containers" json:"containers"`
+	Services   []ServiceStatus   `yaml:"services" json:"services"`
+}
+
+type ContainerStatus struct {
+	Name  string `yaml:"name" json:"name"`
+	State string `yaml:"state" json:"state"`
+}
+
+type ServiceStatus struct {
+	ID     string `yaml:"id" json:"id"`
+	Health string `yaml:"health" json:"health"`
+}
+
+// List returns the status of all pods managed by mads.
+func (o *Orchestrator) List(ctx context.Context) ([]PodStatus, error) {
+	// Find all pods with the mads label
+	list, err := o.pclient.Pods().List(ctx, map[string][]string{
+		"label": {lastAppliedLabel},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list pods: %s", err)
+	}
+
+	statuses := []PodStatus{}
+	for _, entry := range list {
+		// Get pod info
+		info, err := o.pclient.Pods().Inspect(ctx, entry.Id)
+		if err != nil {
+			return nil, fmt.Errorf("could not get info on pod '%s': %s", entry.Name, err)
+		}
+
+		// Pods created by older versions of mads don't have the pod name label
+		name := info.Labels[podNameLabel]
+		if name == "" {
+			name = info.Name
+		}
+
+		status := PodStatus{
+			Name:       name,
+			Instance:   info.Name,
+			State:      info.State,
+			Containers: []ContainerStatus{},
+			Services:   []ServiceStatus{},
+		}
+
+		// Add container states
+		for _, c := range info.Containers {
+			// Skip the infra container
+			if c.Id == info.InfraContainerID {
+				continue
+			}
+
+			status.Containers = append(status.Containers, ContainerStatus{
+				Name:  strings.TrimPrefix(c.Name, info.Name+"-"),
+				State: c.State,
+			})
+		}
+
+		// Add service health from consul
+		for _, id := range splitServiceIDs(info) {
+			health, svc, err := o.cclient.Agent().AgentHealthServiceByID(id)
+			if err != nil {
+				return nil, fmt.Errorf("could not get health of consul service '%s': %s", id, err)
+			}
+
+			// Service is not registered
+			if svc == nil {
+				health = ServiceHealthMissing
+			}
+
+			status.Services = append(status.Services, ServiceStatus{ID: id, Health: health})
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
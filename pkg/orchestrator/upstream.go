@@ -0,0 +1,42 @@
+package orchestrator
+
+import (
+	"fmt"
+
+	"github.com/arnarg/mads/pkg/entities"
+)
+
+func validateUpstream(u *entities.ServiceConnectSidecarProxyUpstream) error {
+	if u.DestinationName == "" {
+		return fmt.Errorf("upstream needs a destinationName")
+	}
+
+	switch u.DestinationType {
+	case "", entities.UpstreamDestinationService, entities.UpstreamDestinationPreparedQuery:
+	default:
+		return fmt.Errorf("upstream '%s': unknown destinationType '%s'", u.DestinationName, u.DestinationType)
+	}
+
+	// Listen on either a port or a unix socket
+	if u.LocalBindSocketPath != "" && (u.LocalBindPort != 0 || u.LocalBindAddress != "") {
+		return fmt.Errorf("upstream '%s': localBindSocketPath can't be used with localBindAddress and localBindPort", u.DestinationName)
+	}
+
+	// Peered services are always in the peer's datacenter
+	if u.DestinationPeer != "" && u.Datacenter != "" {
+		return fmt.Errorf("upstream '%s': destinationPeer can't be used with datacenter", u.DestinationName)
+	}
+	if u.DestinationType == entities.UpstreamDestinationPreparedQuery && (u.DestinationPeer != "" || u.DestinationPartition != "") {
+		return fmt.Errorf("upstream '%s': prepared queries can't be used with destinationPeer or destinationPartition", u.DestinationName)
+	}
+
+	if u.MeshGateway != nil {
+		switch u.MeshGateway.Mode {
+		case "", entities.MeshGatewayModeNone, entities.MeshGatewayModeLocal, entities.MeshGatewayModeRemote:
+		default:
+			return fmt.Errorf("upstream '%s': unknown mesh gateway mode '%s'", u.DestinationName, u.MeshGateway.Mode)
+		}
+	}
+
+	return nil
+}
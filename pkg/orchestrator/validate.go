@@ -0,0 +1,281 @@
+package orchestrator
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/arnarg/mads/pkg/podman/containers"
+)
+
+// validatePod checks a pod definition for errors that would otherwise
+// only surface halfway through applying it.
+func validatePod(pod *entities.Pod) error {
+	if pod.Resources != nil {
+		if err := validateResources(pod.Resources); err != nil {
+			return fmt.Errorf("resources: %s", err)
+		}
+	}
+
+	for _, ctr := range pod.Containers {
+		if err := validateRestartPolicy(&ctr); err != nil {
+			return fmt.Errorf("container '%s': %s", ctr.Name, err)
+		}
+
+		if ctr.StopTimeout < 0 {
+			return fmt.Errorf("container '%s': stopTimeout can't be negative", ctr.Name)
+		}
+
+		if ctr.StopSignal != "" {
+			if _, err := parseSignal(ctr.StopSignal); err != nil {
+				return fmt.Errorf("container '%s': %s", ctr.Name, err)
+			}
+		}
+
+		if ctr.Resources != nil {
+			if err := validateResources(ctr.Resources); err != nil {
+				return fmt.Errorf("container '%s': resources: %s", ctr.Name, err)
+			}
+		}
+
+		if ctr.HealthCheck != nil {
+			if err := validateHealthCheck(ctr.HealthCheck); err != nil {
+				return fmt.Errorf("container '%s': %s", ctr.Name, err)
+			}
+		}
+
+		if ctr.SecurityContext != nil {
+			if err := validateSecurityContext(ctr.SecurityContext); err != nil {
+				return fmt.Errorf("container '%s': securityContext: %s", ctr.Name, err)
+			}
+		}
+	}
+
+	for _, ctr := range userContainers(pod) {
+		if err := validateValueSources(&ctr); err != nil {
+			return fmt.Errorf("container '%s': %s", ctr.Name, err)
+		}
+	}
+
+	for _, svc := range pod.Services {
+		if svc.Connect.SidecarService == nil || svc.Connect.SidecarService.Proxy == nil {
+			continue
+		}
+		for _, u := range svc.Connect.SidecarService.Proxy.Upstreams {
+			if err := validateUpstream(&u); err != nil {
+				return fmt.Errorf("service '%s': %s", svc.Name, err)
+			}
+		}
+	}
+
+	for _, svc := range pod.Services {
+		if svc.Connect.SidecarService == nil || svc.Connect.SidecarService.TokenFrom == nil {
+			continue
+		}
+		src := svc.Connect.SidecarService.TokenFrom
+		if err := validateValueSource(src); err != nil {
+			return fmt.Errorf("service '%s': tokenFrom: %s", svc.Name, err)
+		}
+		// Podman never returns the data of a secret
+		if src.PodmanSecret != "" {
+			return fmt.Errorf("service '%s': tokenFrom can't be a podman secret", svc.Name)
+		}
+	}
+
+	for _, svc := range pod.Services {
+		if svc.Connect.SidecarService == nil {
+			continue
+		}
+		sc := svc.Connect.SidecarService
+		if sc.AdminBindAddress != "" && net.ParseIP(sc.AdminBindAddress) == nil {
+			return fmt.Errorf("service '%s': adminBindAddress '%s' is not an IP address", svc.Name, sc.AdminBindAddress)
+		}
+		if sc.EnvoyPrometheusBindAddr != "" {
+			if _, _, err := parseBindAddr(sc.EnvoyPrometheusBindAddr); err != nil {
+				return fmt.Errorf("service '%s': envoyPrometheusBindAddr: %s", svc.Name, err)
+			}
+		}
+	}
+
+	if err := validatePodUserNS(pod); err != nil {
+		return err
+	}
+
+	if err := validateInitContainers(pod); err != nil {
+		return err
+	}
+
+	if err := validateVolumes(pod); err != nil {
+		return err
+	}
+
+	if err := validateNetwork(pod); err != nil {
+		return fmt.Errorf("network: %s", err)
+	}
+
+	ctrNames := []string{}
+	for _, ctr := range pod.Containers {
+		ctrNames = append(ctrNames, ctr.Name)
+	}
+	for _, svc := range pod.Services {
+		for _, name := range svc.Containers {
+			if !contains(ctrNames, name) {
+				return fmt.Errorf("service '%s': container '%s' not found", svc.Name, name)
+			}
+		}
+	}
+
+	if err := validateConsulChecks(pod); err != nil {
+		return err
+	}
+
+	if err := validateRollingHostPorts(pod); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateRollingHostPorts makes sure a pod with the rolling update strategy
+// doesn't map fixed host ports, as both instances would need them during a replacement.
+func validateRollingHostPorts(pod *entities.Pod) error {
+	if pod.UpdateStrategy.Type != entities.UpdateStrategyRolling {
+		return nil
+	}
+
+	for _, ctr := range pod.Containers {
+		for _, mapping := range ctr.Ports {
+			if mapping.HostPort != 0 {
+				return fmt.Errorf("container '%s': host port %d can't be used with the rolling update strategy", ctr.Name, mapping.HostPort)
+			}
+		}
+	}
+
+	// Expose paths are published on the same port on the host
+	for _, svc := range pod.Services {
+		if svc.Connect.Native || svc.Connect.SidecarService == nil || svc.Connect.SidecarService.Proxy == nil {
+			continue
+		}
+		if len(svc.Connect.SidecarService.Proxy.Expose.Paths) > 0 {
+			return fmt.Errorf("service '%s': expose paths can't be used with the rolling update strategy", svc.Name)
+		}
+	}
+
+	// The envoy metrics port is published on the same port on the host
+	for _, svc := range sidecarServices(pod) {
+		if svc.Connect.SidecarService.EnvoyPrometheusBindAddr != "" {
+			return fmt.Errorf("service '%s': envoyPrometheusBindAddr can't be used with the rolling update strategy", svc.Name)
+		}
+	}
+
+	return nil
+}
+
+func validateRestartPolicy(ctr *entities.Container) error {
+	switch ctr.RestartPolicy {
+	case containers.RestartPolicyNo,
+		containers.RestartPolicyAlways,
+		containers.RestartPolicyOnFailure,
+		containers.RestartPolicyUnlessStopped:
+	default:
+		return fmt.Errorf("unknown restart policy '%s'", ctr.RestartPolicy)
+	}
+
+	// Podman only supports retries with on-failure
+	if ctr.RestartRetries > 0 && ctr.RestartPolicy != containers.RestartPolicyOnFailure {
+		return fmt.Errorf("restartRetries can only be used with restart policy '%s'", containers.RestartPolicyOnFailure)
+	}
+
+	return nil
+}
+
+func validateInitContainers(pod *entities.Pod) error {
+	if len(pod.InitContainers) < 1 {
+		return nil
+	}
+
+	switch pod.InitPolicy {
+	case "", entities.InitPolicyAlways, entities.InitPolicyOnce:
+	default:
+		return fmt.Errorf("unknown init policy '%s'", pod.InitPolicy)
+	}
+
+	// Init containers share the naming scheme with the other containers
+	names := map[string]bool{}
+	for _, ctr := range pod.Containers {
+		names[ctr.Name] = true
+	}
+
+	for _, ctr := range pod.InitContainers {
+		if names[ctr.Name] {
+			return fmt.Errorf("init container '%s': name is already used by another container", ctr.Name)
+		}
+		names[ctr.Name] = true
+
+		if len(ctr.Ports) > 0 {
+			return fmt.Errorf("init container '%s': ports can't be used with init containers", ctr.Name)
+		}
+		if ctr.HealthCheck != nil {
+			return fmt.Errorf("init container '%s': healthcheck can't be used with init containers", ctr.Name)
+		}
+		if ctr.SecurityContext != nil {
+			if err := validateSecurityContext(ctr.SecurityContext); err != nil {
+				return fmt.Errorf("init container '%s': securityContext: %s", ctr.Name, err)
+			}
+		}
+		if ctr.Resources != nil {
+			if err := validateResources(ctr.Resources); err != nil {
+				return fmt.Errorf("init container '%s': resources: %s", ctr.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// initPolicy returns the init policy of a pod, "always" if it's not set.
+func initPolicy(pod *entities.Pod) string {
+	if pod.InitPolicy == "" {
+		return entities.InitPolicyAlways
+	}
+	return pod.InitPolicy
+}
+
+// userContainers returns all init containers and containers defined in a pod.
+func userContainers(pod *entities.Pod) []entities.Container {
+	return append(append([]entities.Container{}, pod.InitContainers...), pod.Containers...)
+}
+
+func validateValueSources(ctr *entities.Container) error {
+	for k, v := range ctr.Env {
+		if v.ValueFrom == nil {
+			continue
+		}
+		if err := validateValueSource(v.ValueFrom); err != nil {
+			return fmt.Errorf("env '%s': %s", k, err)
+		}
+	}
+
+	for _, f := range ctr.Files {
+		if f.ContentFrom == nil {
+			continue
+		}
+		if f.Content != "" {
+			return fmt.Errorf("file '%s': only one of content and contentFrom can be set", f.Destination)
+		}
+		if err := validateValueSource(f.ContentFrom); err != nil {
+			return fmt.Errorf("file '%s': %s", f.Destination, err)
+		}
+	}
+
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
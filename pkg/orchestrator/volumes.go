@@ -0,0 +1,128 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/arnarg/mads/pkg/podman/pods"
+	"github.com/arnarg/mads/pkg/podman/volumes"
+)
+
+// createVolumes creates all volumes of a pod that don't exist yet.
+// Existing volumes that weren't created by mads are used as they are.
+func (o *Orchestrator) createVolumes(ctx context.Context, pod *entities.Pod) error {
+	for _, vol := range pod.Volumes {
+		exists, err := o.pclient.Volumes().Exists(ctx, vol.Name)
+		if err != nil {
+			return fmt.Errorf("could not check if volume '%s' exists: %s", vol.Name, err)
+		}
+
+		// Make sure an existing volume doesn't belong to another pod
+		if exists {
+			info, err := o.pclient.Volumes().Inspect(ctx, vol.Name)
+			if err != nil {
+				return fmt.Errorf("could not get info on volume '%s': %s", vol.Name, err)
+			}
+			if owner := info.Labels[podNameLabel]; owner != "" && owner != pod.Name {
+				return fmt.Errorf("volume '%s' belongs to pod '%s'", vol.Name, owner)
+			}
+			continue
+		}
+
+		// Label the volume so it can be found later, the retention policy
+		// is needed to clean it up when its pod is already gone
+		labels := map[string]string{
+			managedLabel:   "true",
+			podNameLabel:   pod.Name,
+			retentionLabel: vol.RetentionPolicy,
+		}
+		for k, v := range vol.Labels {
+			labels[k] = v
+		}
+
+		_, err = o.pclient.Volumes().Create(ctx, &volumes.VolumeCreateRequest{
+			Name:    vol.Name,
+			Driver:  vol.Driver,
+			Labels:  labels,
+			Options: vol.Options,
+		})
+		if err != nil {
+			return fmt.Errorf("could not create volume '%s': %s", vol.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// removeVolumes deletes volumes that mads created for a pod and should be
+// deleted with it according to their retention policy, except the ones in keep.
+func (o *Orchestrator) removeVolumes(ctx context.Context, podName string, vols []entities.Volume, keep []string) error {
+	for _, vol := range vols {
+		if vol.RetentionPolicy != entities.VolumeRetentionDelete || contains(keep, vol.Name) {
+			continue
+		}
+
+		exists, err := o.pclient.Volumes().Exists(ctx, vol.Name)
+		if err != nil {
+			return fmt.Errorf("could not check if volume '%s' exists: %s", vol.Name, err)
+		}
+		if !exists {
+			continue
+		}
+
+		// Never delete volumes that mads didn't create for this pod
+		info, err := o.pclient.Volumes().Inspect(ctx, vol.Name)
+		if err != nil {
+			return fmt.Errorf("could not get info on volume '%s': %s", vol.Name, err)
+		}
+		if info.Labels[managedLabel] != "true" || info.Labels[podNameLabel] != podName {
+			continue
+		}
+
+		err = o.pclient.Volumes().Delete(ctx, vol.Name, false)
+		if err != nil {
+			return fmt.Errorf("could not delete volume '%s': %s", vol.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// lastAppliedVolumes returns the volumes in the last applied configuration of a pod instance.
+func lastAppliedVolumes(info *pods.PodInfo) []entities.Volume {
+	snap, err := entities.DecodeSnapshot(info.Labels[lastAppliedLabel])
+	if err != nil || snap.Pod == nil {
+		return nil
+	}
+	return snap.Pod.Volumes
+}
+
+func volumeNames(vols []entities.Volume) []string {
+	names := []string{}
+	for _, vol := range vols {
+		names = append(names, vol.Name)
+	}
+	return names
+}
+
+func validateVolumes(pod *entities.Pod) error {
+	names := map[string]bool{}
+	for _, vol := range pod.Volumes {
+		if vol.Name == "" {
+			return fmt.Errorf("volume has no name")
+		}
+		if names[vol.Name] {
+			return fmt.Errorf("volume '%s' is defined more than once", vol.Name)
+		}
+		names[vol.Name] = true
+
+		switch vol.RetentionPolicy {
+		case entities.VolumeRetentionRetain, entities.VolumeRetentionDelete:
+		default:
+			return fmt.Errorf("volume '%s': unknown retention policy '%s'", vol.Name, vol.RetentionPolicy)
+		}
+	}
+
+	return nil
+}
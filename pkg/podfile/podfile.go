@@ -0,0 +1,98 @@
+package podfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/arnarg/mads/pkg/template"
+	"gopkg.in/yaml.v3"
+)
+
+// ReadFile reads and parses a pod definition file.
+func ReadFile(p string) ([]*entities.Pod, error) {
+	// Read file
+	def, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file '%s': %s", p, err)
+	}
+
+	// Parse file contents
+	pods, err := Parse(def)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse yaml file '%s': %s", p, err)
+	}
+
+	return pods, nil
+}
+
+// ReadTemplateFile reads a pod definition file, renders it as a template and parses the result.
+func ReadTemplateFile(p string, r *template.Renderer) ([]*entities.Pod, error) {
+	// Read file
+	def, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file '%s': %s", p, err)
+	}
+
+	// Render template
+	def, _, err = r.Render(p, def)
+	if err != nil {
+		return nil, fmt.Errorf("could not render template '%s': %s", p, err)
+	}
+
+	// Parse file contents
+	pods, err := Parse(def)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse yaml file '%s': %s", p, err)
+	}
+
+	return pods, nil
+}
+
+// Parse parses pod definitions from a YAML stream.
+// Multiple pods can be defined in separate documents separated by `---`.
+func Parse(buf []byte) ([]*entities.Pod, error) {
+	pods := []*entities.Pod{}
+	names := map[string]bool{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(buf))
+	for {
+		// Decode the next document
+		node := &yaml.Node{}
+		err := dec.Decode(node)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip empty documents
+		if len(node.Content) < 1 || node.Content[0].Tag == "!!null" {
+			continue
+		}
+
+		// Decode pod definition
+		pod := &entities.Pod{}
+		err = node.Decode(pod)
+		if err != nil {
+			return nil, err
+		}
+
+		// Every pod needs a unique name
+		if pod.Name == "" {
+			return nil, fmt.Errorf("pod definition on line %d has no name", node.Line)
+		}
+		if names[pod.Name] {
+			return nil, fmt.Errorf("pod '%s' is defined more than once", pod.Name)
+		}
+		names[pod.Name] = true
+
+		pods = append(pods, pod)
+	}
+
+	return pods, nil
+}
@@ -1,10 +1,13 @@
 package containers
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
 
 	"github.com/arnarg/mads/pkg/entities"
 	"github.com/go-resty/resty/v2"
@@ -45,6 +48,87 @@ func (c *Client) Create(ctx context.Context, ctr *ContainerCreateRequest) error
 	return nil
 }
 
+// Inspect returns info about a container.
+func (c *Client) Inspect(ctx context.Context, nameOrID string) (*ContainerInfo, error) {
+	res, err := c.client.R().
+		ForceContentType("application/json").
+		SetPathParam("id", nameOrID).
+		Get("/v4/libpod/containers/{id}/json")
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse error message")
+		}
+		return nil, e
+	}
+
+	// Parse JSON
+	ctr := &ContainerInfo{}
+	err = json.Unmarshal(res.Body(), ctr)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctr, nil
+}
+
+// Logs returns the last lines of stdout and stderr of a container.
+func (c *Client) Logs(ctx context.Context, nameOrID string, tail int) (string, error) {
+	res, err := c.client.R().
+		SetPathParam("id", nameOrID).
+		SetQueryParams(map[string]string{
+			"stdout": "true",
+			"stderr": "true",
+			"tail":   strconv.Itoa(tail),
+		}).
+		Get("/v4/libpod/containers/{id}/logs")
+	if err != nil {
+		return "", err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return "", fmt.Errorf("could not parse error message")
+		}
+		return "", e
+	}
+
+	return demuxLogs(res.Body()), nil
+}
+
+// demuxLogs strips the stream headers from multiplexed container logs.
+// Each frame starts with an 8 byte header where the first byte is the stream
+// and the last 4 bytes are the big endian length of the frame.
+// Logs of containers with a terminal are not multiplexed and returned as is.
+func demuxLogs(buf []byte) string {
+	out := &bytes.Buffer{}
+
+	for rest := buf; len(rest) > 0; {
+		if len(rest) < 8 || rest[0] > 2 || !bytes.Equal(rest[1:4], []byte{0, 0, 0}) {
+			return string(buf)
+		}
+
+		size := int(binary.BigEndian.Uint32(rest[4:8]))
+		if len(rest) < 8+size {
+			return string(buf)
+		}
+
+		out.Write(rest[8 : 8+size])
+		rest = rest[8+size:]
+	}
+
+	return out.String()
+}
+
 func (c *Client) Copy(ctx context.Context, nameOrID string, w io.Reader) error {
 	res, err := c.client.R().
 		ForceContentType("application/x-tar").
@@ -1,5 +1,7 @@
 package containers
 
+import "time"
+
 const (
 	MountTypeBind   = "bind"
 	MountTypeVolume = "volume"
@@ -9,18 +11,121 @@ const (
 	RestartPolicyAlways        = "always"
 	RestartPolicyOnFailure     = "on-failure"
 	RestartPolicyUnlessStopped = "unless-stopped"
+
+	StateRunning = "running"
+	StateExited  = "exited"
+
+	// Init containers of type once are removed after they have run once,
+	// the ones of type always run every time the pod is started.
+	InitContainerOnce   = "once"
+	InitContainerAlways = "always"
+
+	HealthStatusHealthy   = "healthy"
+	HealthStatusUnhealthy = "unhealthy"
+	HealthStatusStarting  = "starting"
 )
 
+type ContainerInfo struct {
+	Id         string
+	Name       string
+	Pod        string
+	IsInfra    bool
+	State      ContainerState
+	Config     ContainerConfig
+	HostConfig ContainerHostConfig
+}
+
+type ContainerConfig struct {
+	Labels map[string]string
+}
+
+type ContainerHostConfig struct {
+	RestartPolicy ContainerRestartPolicy
+}
+
+type ContainerRestartPolicy struct {
+	Name              string
+	MaximumRetryCount uint
+}
+
+type ContainerState struct {
+	Status   string
+	Running  bool
+	ExitCode int32
+	Error    string
+	Health   ContainerHealth
+	// Older podman versions use this name for the health status
+	Healthcheck ContainerHealth
+}
+
+// HealthStatus returns the health status of the container or an empty
+// string if it has no healthcheck.
+func (s *ContainerState) HealthStatus() string {
+	if s.Health.Status != "" {
+		return s.Health.Status
+	}
+	return s.Healthcheck.Status
+}
+
+type ContainerHealth struct {
+	Status        string
+	FailingStreak int
+}
+
 type ContainerCreateRequest struct {
 	Name          string            `json:"name"`
 	Image         string            `json:"image"`
 	Namespace     string            `json:"namespace,omitempty"`
 	Pod           string            `json:"pod,omitempty"`
 	RestartPolicy string            `json:"restart_policy,omitempty"`
+	RestartTries  uint              `json:"restart_tries,omitempty"`
 	Command       []string          `json:"command,omitempty"`
+	Entrypoint    []string          `json:"entrypoint,omitempty"`
+	WorkDir       string            `json:"work_dir,omitempty"`
+	StopSignal    int               `json:"stop_signal,omitempty"`
+	StopTimeout   uint              `json:"stop_timeout,omitempty"`
+	Terminal      bool              `json:"terminal,omitempty"`
+	Stdin         bool              `json:"stdin,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
 	Mounts        []ContainerMount  `json:"mounts,omitempty"`
 	HostAdd       []string          `json:"hostadd,omitempty"`
 	Env           map[string]string `json:"env,omitempty"`
+	// Environment variables set from podman secrets, mapped to the secret name
+	SecretEnv map[string]string `json:"secret_env,omitempty"`
+	// Podman secrets mounted as files
+	Secrets      []Secret      `json:"secrets,omitempty"`
+	HealthConfig *HealthConfig `json:"healthconfig,omitempty"`
+	// Resource limits of the container cgroup
+	ResourceLimits *LinuxResources `json:"resource_limits,omitempty"`
+	// Security settings
+	User               string   `json:"user,omitempty"`
+	CapAdd             []string `json:"cap_add,omitempty"`
+	CapDrop            []string `json:"cap_drop,omitempty"`
+	ReadOnlyFilesystem bool     `json:"read_only_filesystem,omitempty"`
+	Privileged         bool     `json:"privileged,omitempty"`
+	NoNewPrivileges    bool     `json:"no_new_privileges,omitempty"`
+	SeccompProfilePath string   `json:"seccomp_profile_path,omitempty"`
+	SelinuxOpts        []string `json:"selinux_opts,omitempty"`
+	// Makes the container an init container that runs to completion before
+	// the other containers in the pod are started
+	InitContainerType string `json:"init_container_type,omitempty"`
+}
+
+// Secret is a podman secret mounted into a container.
+// The target is a path inside the container.
+type Secret struct {
+	Source string
+	Target string
+	UID    uint32
+	GID    uint32
+	Mode   uint32
+}
+
+// Namespace is the configuration of a single namespace of a container or pod.
+type Namespace struct {
+	NSMode string `json:"nsmode,omitempty"`
+	Value  string `json:"value,omitempty"`
 }
 
 type ContainerMount struct {
@@ -29,3 +134,36 @@ type ContainerMount struct {
 	Type        string   `json:"type,omitempty"`
 	Options     []string `json:"options,omitempty"`
 }
+
+// HealthConfig mirrors podman's native (docker schema2) healthcheck config.
+type HealthConfig struct {
+	Test        []string      `json:",omitempty"`
+	Interval    time.Duration `json:",omitempty"`
+	Timeout     time.Duration `json:",omitempty"`
+	StartPeriod time.Duration `json:",omitempty"`
+	Retries     int           `json:",omitempty"`
+}
+
+// LinuxResources is a subset of the OCI runtime spec resource limits.
+type LinuxResources struct {
+	Memory *LinuxMemory `json:"memory,omitempty"`
+	CPU    *LinuxCPU    `json:"cpu,omitempty"`
+	Pids   *LinuxPids   `json:"pids,omitempty"`
+}
+
+type LinuxMemory struct {
+	Limit       *int64 `json:"limit,omitempty"`
+	Reservation *int64 `json:"reservation,omitempty"`
+	Swap        *int64 `json:"swap,omitempty"`
+}
+
+type LinuxCPU struct {
+	Shares *uint64 `json:"shares,omitempty"`
+	Quota  *int64  `json:"quota,omitempty"`
+	Period *uint64 `json:"period,omitempty"`
+	Cpus   string  `json:"cpus,omitempty"`
+}
+
+type LinuxPids struct {
+	Limit int64 `json:"limit"`
+}
@@ -0,0 +1,168 @@
+package networks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/go-resty/resty/v2"
+)
+
+type Client struct {
+	client *resty.Client
+}
+
+func NewClient(c *resty.Client) *Client {
+	return &Client{client: c}
+}
+
+// Exists checks if a network by name or ID exists.
+func (c *Client) Exists(ctx context.Context, nameOrID string) (bool, error) {
+	res, err := c.client.R().
+		ForceContentType("application/json").
+		SetPathParam("id", nameOrID).
+		Get("/v4/libpod/networks/{id}/exists")
+	if err != nil {
+		return false, err
+	}
+
+	if res.StatusCode() == 204 {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Inspect returns info about a network.
+func (c *Client) Inspect(ctx context.Context, nameOrID string) (*NetworkInfo, error) {
+	res, err := c.client.R().
+		ForceContentType("application/json").
+		SetPathParam("id", nameOrID).
+		Get("/v4/libpod/networks/{id}/json")
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse error message")
+		}
+		return nil, e
+	}
+
+	// Parse JSON
+	network := &NetworkInfo{}
+	err = json.Unmarshal(res.Body(), network)
+	if err != nil {
+		return nil, err
+	}
+
+	return network, nil
+}
+
+// List lists networks, optionally filtered.
+// Filters are passed to podman as is, e.g. {"label": ["key=value"]}.
+func (c *Client) List(ctx context.Context, filters map[string][]string) ([]NetworkInfo, error) {
+	req := c.client.R().
+		ForceContentType("application/json")
+
+	// Add filters
+	if len(filters) > 0 {
+		buf, err := json.Marshal(filters)
+		if err != nil {
+			return nil, err
+		}
+		req.SetQueryParam("filters", string(buf))
+	}
+
+	res, err := req.Get("/v4/libpod/networks/json")
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse error message")
+		}
+		return nil, e
+	}
+
+	// Parse JSON
+	list := []NetworkInfo{}
+	err = json.Unmarshal(res.Body(), &list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Create creates a new network.
+func (c *Client) Create(ctx context.Context, network *NetworkCreateRequest) (*NetworkInfo, error) {
+	res, err := c.client.R().
+		ForceContentType("application/json").
+		SetBody(network).
+		Post("/v4/libpod/networks/create")
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse error message")
+		}
+		return nil, e
+	}
+
+	if res.StatusCode() != 200 {
+		return nil, fmt.Errorf("unknown status code %d", res.StatusCode())
+	}
+
+	// Parse JSON
+	info := &NetworkInfo{}
+	err = json.Unmarshal(res.Body(), info)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// Delete deletes a network.
+func (c *Client) Delete(ctx context.Context, nameOrID string, force bool) error {
+	res, err := c.client.R().
+		ForceContentType("application/json").
+		SetQueryParam("force", strconv.FormatBool(force)).
+		SetPathParam("id", nameOrID).
+		Delete("/v4/libpod/networks/{id}")
+	if err != nil {
+		return err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return fmt.Errorf("could not parse error message")
+		}
+		return e
+	}
+
+	if res.StatusCode() != 200 {
+		return fmt.Errorf("unknown status code %d", res.StatusCode())
+	}
+
+	return nil
+}
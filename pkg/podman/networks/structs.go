@@ -0,0 +1,26 @@
+package networks
+
+type NetworkInfo struct {
+	Name             string            `json:"name"`
+	ID               string            `json:"id"`
+	Driver           string            `json:"driver"`
+	NetworkInterface string            `json:"network_interface,omitempty"`
+	Subnets          []Subnet          `json:"subnets,omitempty"`
+	IPv6Enabled      bool              `json:"ipv6_enabled"`
+	Internal         bool              `json:"internal"`
+	DNSEnabled       bool              `json:"dns_enabled"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	Options          map[string]string `json:"options,omitempty"`
+}
+
+type Subnet struct {
+	Subnet  string `json:"subnet"`
+	Gateway string `json:"gateway,omitempty"`
+}
+
+type NetworkCreateRequest struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver,omitempty"`
+	DNSEnabled bool              `json:"dns_enabled"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
@@ -6,7 +6,10 @@ import (
 
 	"github.com/arnarg/mads/pkg/podman/containers"
 	"github.com/arnarg/mads/pkg/podman/images"
+	"github.com/arnarg/mads/pkg/podman/networks"
 	"github.com/arnarg/mads/pkg/podman/pods"
+	"github.com/arnarg/mads/pkg/podman/secrets"
+	"github.com/arnarg/mads/pkg/podman/volumes"
 	"github.com/go-resty/resty/v2"
 )
 
@@ -47,3 +50,15 @@ func (c *Client) Images() *images.Client {
 func (c *Client) Containers() *containers.Client {
 	return containers.NewClient(c.client)
 }
+
+func (c *Client) Secrets() *secrets.Client {
+	return secrets.NewClient(c.client)
+}
+
+func (c *Client) Volumes() *volumes.Client {
+	return volumes.NewClient(c.client)
+}
+
+func (c *Client) Networks() *networks.Client {
+	return networks.NewClient(c.client)
+}
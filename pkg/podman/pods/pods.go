@@ -47,6 +47,46 @@ func (p *Client) Exists(ctx context.Context, nameOrID string) (bool, string, err
 	return true, info.Id, nil
 }
 
+// List lists pods, optionally filtered.
+// Filters are passed to podman as is, e.g. {"label": ["key=value"]}.
+func (p *Client) List(ctx context.Context, filters map[string][]string) ([]PodListEntry, error) {
+	req := p.client.R().
+		ForceContentType("application/json")
+
+	// Add filters
+	if len(filters) > 0 {
+		buf, err := json.Marshal(filters)
+		if err != nil {
+			return nil, err
+		}
+		req.SetQueryParam("filters", string(buf))
+	}
+
+	res, err := req.Get("/v4/libpod/pods/json")
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse error message")
+		}
+		return nil, e
+	}
+
+	// Parse JSON
+	list := []PodListEntry{}
+	err = json.Unmarshal(res.Body(), &list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
 // Inspect returns info about pod.
 func (p *Client) Inspect(ctx context.Context, nameOrID string) (*PodInfo, error) {
 	res, err := p.client.R().
@@ -120,7 +160,21 @@ func (p *Client) Start(ctx context.Context, nameOrID string) error {
 
 	if res.StatusCode() == 304 {
 		return ErrPodAlreadyStarted
-	} else if res.StatusCode() != 200 {
+	}
+
+	// Handle error message.
+	// Podman either responds with a normal error message or a report
+	// where errors of individual containers can't be serialized.
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil || e.Message == "" {
+			return fmt.Errorf("could not start pod (%d)", res.StatusCode())
+		}
+		return e
+	}
+
+	if res.StatusCode() != 200 {
 		return fmt.Errorf("unknown status code %d", res.StatusCode())
 	}
 
@@ -1,5 +1,7 @@
 package pods
 
+import "github.com/arnarg/mads/pkg/podman/containers"
+
 const (
 	PodStateCreated = "Created"
 	PodStateRunning = "Running"
@@ -28,6 +30,23 @@ type PodInfo struct {
 	NumContainers    int
 }
 
+type PodListEntry struct {
+	Id         string
+	Name       string
+	Namespace  string
+	Status     string
+	Created    string
+	InfraId    string
+	Labels     map[string]string
+	Containers []PodListContainer
+}
+
+type PodListContainer struct {
+	Id     string
+	Names  string
+	Status string
+}
+
 type PodInfraConfig struct {
 	PortBindings        map[string][]PortBinding
 	HostNetwork         bool
@@ -56,6 +75,23 @@ type PodCreateRequest struct {
 	Labels       map[string]string `json:"labels,omitempty"`
 	PortMappings []PodPortMapping  `json:"portmappings,omitempty"`
 	HostAdd      []string          `json:"hostadd,omitempty"`
+	// Resource limits of the pod cgroup
+	ResourceLimits *containers.LinuxResources `json:"resource_limits,omitempty"`
+	// User namespace shared by all containers in the pod
+	UserNS *containers.Namespace `json:"userns,omitempty"`
+	// Network settings
+	NetNS     *containers.Namespace        `json:"netns,omitempty"`
+	Networks  map[string]PerNetworkOptions `json:"Networks,omitempty"`
+	DNSServer []string                     `json:"dns_server,omitempty"`
+	DNSSearch []string                     `json:"dns_search,omitempty"`
+	DNSOption []string                     `json:"dns_option,omitempty"`
+}
+
+// PerNetworkOptions are the options of a pod in a single podman network.
+type PerNetworkOptions struct {
+	StaticIPs []string `json:"static_ips,omitempty"`
+	StaticMAC string   `json:"static_mac,omitempty"`
+	Aliases   []string `json:"aliases,omitempty"`
 }
 
 type PodPortMapping struct {
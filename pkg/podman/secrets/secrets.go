@@ -0,0 +1,192 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/go-resty/resty/v2"
+)
+
+type Client struct {
+	client *resty.Client
+}
+
+func NewClient(c *resty.Client) *Client {
+	return &Client{client: c}
+}
+
+// Exists checks if a secret by name or ID exists.
+func (c *Client) Exists(ctx context.Context, nameOrID string) (bool, error) {
+	res, err := c.client.R().
+		ForceContentType("application/json").
+		SetPathParam("id", nameOrID).
+		Get("/v4/libpod/secrets/{id}/json")
+	if err != nil {
+		return false, err
+	}
+
+	if res.StatusCode() == 404 {
+		return false, nil
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return false, fmt.Errorf("could not parse error message")
+		}
+		return false, e
+	}
+
+	return true, nil
+}
+
+// Inspect returns info about a secret. The secret data is never returned.
+func (c *Client) Inspect(ctx context.Context, nameOrID string) (*SecretInfo, error) {
+	res, err := c.client.R().
+		ForceContentType("application/json").
+		SetPathParam("id", nameOrID).
+		Get("/v4/libpod/secrets/{id}/json")
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse error message")
+		}
+		return nil, e
+	}
+
+	// Parse JSON
+	secret := &SecretInfo{}
+	err = json.Unmarshal(res.Body(), secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// List lists secrets, optionally filtered.
+// Filters are passed to podman as is, e.g. {"name": ["mysecret"]}.
+func (c *Client) List(ctx context.Context, filters map[string][]string) ([]SecretInfo, error) {
+	req := c.client.R().
+		ForceContentType("application/json")
+
+	// Add filters
+	if len(filters) > 0 {
+		buf, err := json.Marshal(filters)
+		if err != nil {
+			return nil, err
+		}
+		req.SetQueryParam("filters", string(buf))
+	}
+
+	res, err := req.Get("/v4/libpod/secrets/json")
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse error message")
+		}
+		return nil, e
+	}
+
+	// Parse JSON
+	list := []SecretInfo{}
+	err = json.Unmarshal(res.Body(), &list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Create creates a new secret and returns its ID.
+func (c *Client) Create(ctx context.Context, secret *SecretCreateRequest) (string, error) {
+	req := c.client.R().
+		ForceContentType("application/json").
+		SetQueryParam("name", secret.Name).
+		SetBody(bytes.NewReader(secret.Data))
+
+	if secret.Driver != "" {
+		req.SetQueryParam("driver", secret.Driver)
+	}
+
+	// Add labels
+	if len(secret.Labels) > 0 {
+		buf, err := json.Marshal(secret.Labels)
+		if err != nil {
+			return "", err
+		}
+		req.SetQueryParam("labels", string(buf))
+	}
+
+	res, err := req.Post("/v4/libpod/secrets/create")
+	if err != nil {
+		return "", err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return "", fmt.Errorf("could not parse error message")
+		}
+		return "", e
+	}
+
+	if res.StatusCode() != 200 {
+		return "", fmt.Errorf("unknown status code %d", res.StatusCode())
+	}
+
+	// Parse JSON
+	created := &SecretCreateResponse{}
+	err = json.Unmarshal(res.Body(), created)
+	if err != nil {
+		return "", err
+	}
+
+	return created.ID, nil
+}
+
+// Delete deletes a secret.
+func (c *Client) Delete(ctx context.Context, nameOrID string) error {
+	res, err := c.client.R().
+		ForceContentType("application/json").
+		SetPathParam("id", nameOrID).
+		Delete("/v4/libpod/secrets/{id}")
+	if err != nil {
+		return err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return fmt.Errorf("could not parse error message")
+		}
+		return e
+	}
+
+	if res.StatusCode() != 204 {
+		return fmt.Errorf("unknown status code %d", res.StatusCode())
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package secrets
+
+type SecretInfo struct {
+	ID        string
+	CreatedAt string
+	UpdatedAt string
+	Spec      SecretSpec
+}
+
+type SecretSpec struct {
+	Name   string
+	Driver SecretDriverSpec
+	Labels map[string]string
+}
+
+type SecretDriverSpec struct {
+	Name    string
+	Options map[string]string
+}
+
+type SecretCreateRequest struct {
+	Name   string
+	Driver string
+	Labels map[string]string
+	Data   []byte
+}
+
+type SecretCreateResponse struct {
+	ID string
+}
@@ -0,0 +1,18 @@
+package volumes
+
+type VolumeInfo struct {
+	Name       string
+	Driver     string
+	Mountpoint string
+	CreatedAt  string
+	Labels     map[string]string
+	Scope      string
+	Options    map[string]string
+}
+
+type VolumeCreateRequest struct {
+	Name    string            `json:"Name"`
+	Driver  string            `json:"Driver,omitempty"`
+	Labels  map[string]string `json:"Label,omitempty"`
+	Options map[string]string `json:"Options,omitempty"`
+}
@@ -0,0 +1,168 @@
+package volumes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/arnarg/mads/pkg/entities"
+	"github.com/go-resty/resty/v2"
+)
+
+type Client struct {
+	client *resty.Client
+}
+
+func NewClient(c *resty.Client) *Client {
+	return &Client{client: c}
+}
+
+// Exists checks if a volume exists.
+func (c *Client) Exists(ctx context.Context, name string) (bool, error) {
+	res, err := c.client.R().
+		ForceContentType("application/json").
+		SetPathParam("name", name).
+		Get("/v4/libpod/volumes/{name}/exists")
+	if err != nil {
+		return false, err
+	}
+
+	if res.StatusCode() == 204 {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Inspect returns info about a volume.
+func (c *Client) Inspect(ctx context.Context, name string) (*VolumeInfo, error) {
+	res, err := c.client.R().
+		ForceContentType("application/json").
+		SetPathParam("name", name).
+		Get("/v4/libpod/volumes/{name}/json")
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse error message")
+		}
+		return nil, e
+	}
+
+	// Parse JSON
+	vol := &VolumeInfo{}
+	err = json.Unmarshal(res.Body(), vol)
+	if err != nil {
+		return nil, err
+	}
+
+	return vol, nil
+}
+
+// List lists volumes, optionally filtered.
+// Filters are passed to podman as is, e.g. {"label": ["key=value"]}.
+func (c *Client) List(ctx context.Context, filters map[string][]string) ([]VolumeInfo, error) {
+	req := c.client.R().
+		ForceContentType("application/json")
+
+	// Add filters
+	if len(filters) > 0 {
+		buf, err := json.Marshal(filters)
+		if err != nil {
+			return nil, err
+		}
+		req.SetQueryParam("filters", string(buf))
+	}
+
+	res, err := req.Get("/v4/libpod/volumes/json")
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse error message")
+		}
+		return nil, e
+	}
+
+	// Parse JSON
+	list := []VolumeInfo{}
+	err = json.Unmarshal(res.Body(), &list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Create creates a new volume.
+func (c *Client) Create(ctx context.Context, vol *VolumeCreateRequest) (*VolumeInfo, error) {
+	res, err := c.client.R().
+		ForceContentType("application/json").
+		SetBody(vol).
+		Post("/v4/libpod/volumes/create")
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse error message")
+		}
+		return nil, e
+	}
+
+	if res.StatusCode() != 201 {
+		return nil, fmt.Errorf("unknown status code %d", res.StatusCode())
+	}
+
+	// Parse JSON
+	info := &VolumeInfo{}
+	err = json.Unmarshal(res.Body(), info)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// Delete deletes a volume.
+func (c *Client) Delete(ctx context.Context, name string, force bool) error {
+	res, err := c.client.R().
+		ForceContentType("application/json").
+		SetQueryParam("force", strconv.FormatBool(force)).
+		SetPathParam("name", name).
+		Delete("/v4/libpod/volumes/{name}")
+	if err != nil {
+		return err
+	}
+
+	// Handle error message
+	if res.StatusCode() >= 400 {
+		e := &entities.PodmanAPIError{}
+		err := json.Unmarshal(res.Body(), e)
+		if err != nil {
+			return fmt.Errorf("could not parse error message")
+		}
+		return e
+	}
+
+	if res.StatusCode() != 204 {
+		return fmt.Errorf("unknown status code %d", res.StatusCode())
+	}
+
+	return nil
+}
@@ -0,0 +1,292 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	DependencyKey     = "key"
+	DependencyService = "service"
+	DependencyNode    = "node"
+)
+
+// How long a single blocking query to consul waits for changes
+const waitTime = 5 * time.Minute
+
+// How long to wait before retrying a failed blocking query
+const retryInterval = 5 * time.Second
+
+// Dependency is a piece of consul data that a rendered template used,
+// along with the consul index it had when it was read.
+type Dependency struct {
+	Type  string
+	Name  string
+	Index uint64
+}
+
+type Config struct {
+	// Consul client configuration, defaults are used if nil
+	ConsulConfig *api.Config
+}
+
+// Renderer renders pod definition files as Go templates with functions,
+// similar to consul-template, that read data from consul.
+type Renderer struct {
+	client *api.Client
+}
+
+func NewRenderer(cfg *Config) (*Renderer, error) {
+	ccfg := cfg.ConsulConfig
+	if ccfg == nil {
+		ccfg = api.DefaultConfig()
+	}
+
+	// Create consul client
+	client, err := api.NewClient(ccfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Renderer{client: client}, nil
+}
+
+// Service is a healthy instance of a service in the consul catalog.
+type Service struct {
+	ID      string
+	Name    string
+	Node    string
+	Address string
+	Port    int
+	Tags    []string
+	Meta    map[string]string
+}
+
+// Node is the consul node the agent is running on.
+type Node struct {
+	Name       string
+	Address    string
+	Datacenter string
+	Meta       map[string]string
+}
+
+// Render executes a template and returns the result along with the consul
+// data it depends on. The dependencies are also returned when rendering
+// fails so the caller can wait for missing data to show up.
+func (r *Renderer) Render(name string, buf []byte) ([]byte, []Dependency, error) {
+	rc := &renderContext{client: r.client}
+
+	tmpl, err := template.New(name).
+		Funcs(rc.funcs()).
+		Parse(string(buf))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := &bytes.Buffer{}
+	err = tmpl.Execute(out, nil)
+	if err != nil {
+		return nil, rc.deps, err
+	}
+
+	return out.Bytes(), rc.deps, nil
+}
+
+// Wait blocks until any of the dependencies changes or the context is cancelled.
+func (r *Renderer) Wait(ctx context.Context, deps []Dependency) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changed := make(chan struct{}, len(deps))
+	for _, dep := range deps {
+		go func(dep Dependency) {
+			if err := r.waitDependency(ctx, dep); err == nil {
+				changed <- struct{}{}
+			}
+		}(dep)
+	}
+
+	select {
+	case <-changed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitDependency runs blocking queries for a dependency until its index changes.
+func (r *Renderer) waitDependency(ctx context.Context, dep Dependency) error {
+	for {
+		opts := (&api.QueryOptions{
+			WaitIndex: dep.Index,
+			WaitTime:  waitTime,
+		}).WithContext(ctx)
+
+		index, err := query(r.client, dep, opts)
+		if err != nil {
+			select {
+			case <-time.After(retryInterval):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		// Consul can also reset the index, any change means the data might have changed
+		if index != dep.Index {
+			return nil
+		}
+	}
+}
+
+// query reads the data of a dependency and returns its current index.
+func query(client *api.Client, dep Dependency, opts *api.QueryOptions) (uint64, error) {
+	var meta *api.QueryMeta
+	var err error
+
+	switch dep.Type {
+	case DependencyKey:
+		_, meta, err = client.KV().Get(dep.Name, opts)
+	case DependencyService:
+		_, meta, err = client.Health().Service(dep.Name, "", true, opts)
+	case DependencyNode:
+		_, meta, err = client.Catalog().Node(dep.Name, opts)
+	default:
+		return 0, fmt.Errorf("unknown dependency type '%s'", dep.Type)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return meta.LastIndex, nil
+}
+
+// renderContext records the dependencies of a single render.
+type renderContext struct {
+	client *api.Client
+	deps   []Dependency
+}
+
+func (rc *renderContext) funcs() template.FuncMap {
+	return template.FuncMap{
+		"key":          rc.key,
+		"keyOrDefault": rc.keyOrDefault,
+		"service":      rc.service,
+		"node":         rc.node,
+		"env":          os.Getenv,
+		"file":         file,
+	}
+}
+
+func (rc *renderContext) addDependency(typ, name string, index uint64) {
+	rc.deps = append(rc.deps, Dependency{Type: typ, Name: name, Index: index})
+}
+
+// key returns the value of a key in consul's KV store and fails if it doesn't exist.
+func (rc *renderContext) key(name string) (string, error) {
+	pair, meta, err := rc.client.KV().Get(name, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not read consul key '%s': %s", name, err)
+	}
+	rc.addDependency(DependencyKey, name, meta.LastIndex)
+
+	if pair == nil {
+		return "", fmt.Errorf("consul key '%s' does not exist", name)
+	}
+
+	return string(pair.Value), nil
+}
+
+// keyOrDefault returns the value of a key in consul's KV store or def if it doesn't exist.
+func (rc *renderContext) keyOrDefault(name, def string) (string, error) {
+	pair, meta, err := rc.client.KV().Get(name, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not read consul key '%s': %s", name, err)
+	}
+	rc.addDependency(DependencyKey, name, meta.LastIndex)
+
+	if pair == nil {
+		return def, nil
+	}
+
+	return string(pair.Value), nil
+}
+
+// service returns all healthy instances of a service.
+func (rc *renderContext) service(name string) ([]Service, error) {
+	entries, meta, err := rc.client.Health().Service(name, "", true, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not get service '%s': %s", name, err)
+	}
+	rc.addDependency(DependencyService, name, meta.LastIndex)
+
+	services := []Service{}
+	for _, entry := range entries {
+		// Services without an address use the address of their node
+		addr := entry.Service.Address
+		if addr == "" {
+			addr = entry.Node.Address
+		}
+
+		services = append(services, Service{
+			ID:      entry.Service.ID,
+			Name:    entry.Service.Service,
+			Node:    entry.Node.Node,
+			Address: addr,
+			Port:    entry.Service.Port,
+			Tags:    entry.Service.Tags,
+			Meta:    entry.Service.Meta,
+		})
+	}
+
+	// Keep the order stable so the rendered output only changes with the data
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Node != services[j].Node {
+			return services[i].Node < services[j].Node
+		}
+		return services[i].ID < services[j].ID
+	})
+
+	return services, nil
+}
+
+// node returns the consul node of the local agent.
+func (rc *renderContext) node() (*Node, error) {
+	name, err := rc.client.Agent().NodeName()
+	if err != nil {
+		return nil, fmt.Errorf("could not get node name: %s", err)
+	}
+
+	cnode, meta, err := rc.client.Catalog().Node(name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not get node '%s': %s", name, err)
+	}
+	rc.addDependency(DependencyNode, name, meta.LastIndex)
+
+	if cnode == nil || cnode.Node == nil {
+		return nil, fmt.Errorf("node '%s' is not in the catalog", name)
+	}
+
+	return &Node{
+		Name:       cnode.Node.Node,
+		Address:    cnode.Node.Address,
+		Datacenter: cnode.Node.Datacenter,
+		Meta:       cnode.Node.Meta,
+	}, nil
+}
+
+// file returns the contents of a file on the host.
+func file(p string) (string, error) {
+	buf, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
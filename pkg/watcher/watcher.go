@@ -3,59 +3,91 @@ package watcher
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/arnarg/mads/pkg/entities"
+	"github.com/arnarg/mads/pkg/podfile"
+	"github.com/arnarg/mads/pkg/template"
 	"github.com/fsnotify/fsnotify"
-	"gopkg.in/yaml.v3"
 )
 
 const (
 	TypeApply  = "apply"
 	TypeDelete = "delete"
+	// TypeInvalid is sent when a file could not be read or parsed.
+	// Pods from the last valid version of the file are left as they are.
+	TypeInvalid = "invalid"
 )
 
 type PodFileEvent struct {
 	Type string
 	Name string
+	Path string
 	Pod  *entities.Pod
+	Err  error
+}
+
+type Config struct {
+	// Path is the directory to watch, including all of its subdirectories.
+	Path string
+	// Extensions are the file extensions of pod definition files, e.g. ".yaml".
+	// All other files are ignored.
+	Extensions []string
+	// Debounce is how long a file has to be left alone after an event before it's read.
+	// Editors and tools like cp write files in several chunks and we only want to read
+	// the file once it's complete.
+	Debounce time.Duration
+	// Renderer renders files as templates before they are parsed, templating is disabled if nil.
+	// Files are read again when consul data their templates depend on changes.
+	Renderer *template.Renderer
 }
 
 type FileWatcher struct {
-	path string
-	ch   chan *PodFileEvent
-	pods map[string]*entities.Pod
+	path       string
+	extensions []string
+	debounce   time.Duration
+	ch         chan *PodFileEvent
+	mu         sync.Mutex
+	pods       map[string][]*entities.Pod
+	synced     chan struct{}
+	// Files that could not be read or parsed the last time they were read
+	invalid map[string]error
+	// Paths with pending events and when they should be processed
+	pending map[string]time.Time
+	// Hashes of the last pods sent in apply events, guarded by mu
+	sent map[string]string
+	// Template renderer and cancel functions of the dependency watches of every file
+	renderer  *template.Renderer
+	depCtx    context.Context
+	depWaits  map[string]context.CancelFunc
+	depChange chan string
 }
 
-func NewFileWatcher(p string) *FileWatcher {
+func NewFileWatcher(cfg *Config) *FileWatcher {
 	return &FileWatcher{
-		path: p,
-		ch:   make(chan *PodFileEvent, 100),
-		pods: map[string]*entities.Pod{},
+		path:       cfg.Path,
+		extensions: cfg.Extensions,
+		debounce:   cfg.Debounce,
+		ch:         make(chan *PodFileEvent, 100),
+		pods:       map[string][]*entities.Pod{},
+		synced:     make(chan struct{}),
+		invalid:    map[string]error{},
+		pending:    map[string]time.Time{},
+		sent:       map[string]string{},
+		renderer:   cfg.Renderer,
+		depWaits:   map[string]context.CancelFunc{},
+		depChange:  make(chan string, 100),
 	}
 }
 
 func (w *FileWatcher) Run(ctx context.Context) error {
-	// Before watching we want to parse all files in the directory
-	files, err := os.ReadDir(w.path)
-	if err != nil {
-		return err
-	}
-
-	// Read all files in directory
-	for _, f := range files {
-		// Skip directories
-		if f.IsDir() {
-			continue
-		}
-
-		err := w.parseFile(fmt.Sprintf("%s/%s", w.path, f.Name()))
-		if err != nil {
-			return err
-		}
-	}
-
 	// Create a fsnotify watcher
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -63,15 +95,31 @@ func (w *FileWatcher) Run(ctx context.Context) error {
 	}
 	defer watcher.Close()
 
-	// Watch watch-dir
-	err = watcher.Add(w.path)
+	// Dependency watches of templates are stopped with the watcher
+	var cancel context.CancelFunc
+	w.depCtx, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	// Before watching we want to parse all files in the directory and watch all subdirectories
+	err = w.addDir(watcher, w.path, w.parseFile)
 	if err != nil {
-		return fmt.Errorf("could not watch directory '%s': %s", w.path, err)
+		return err
 	}
 
+	// All files have been read once
+	close(w.synced)
+
 	// Wait for events
 	for {
 		select {
+		// Quiet period of pending files is over
+		case <-w.nextFlush():
+			w.flush()
+
+		// Consul data a template depends on has changed
+		case p := <-w.depChange:
+			w.schedule(p)
+
 		// File event
 		case ev, ok := <-watcher.Events:
 			if !ok {
@@ -81,36 +129,32 @@ func (w *FileWatcher) Run(ctx context.Context) error {
 			switch {
 			// File created or updated
 			case ev.Op == fsnotify.Create || ev.Op == fsnotify.Write:
-				err := w.parseFile(ev.Name)
-				if err != nil {
-					return err
+				// Watch new subdirectories and schedule any files already in them
+				if stat, err := os.Stat(ev.Name); err == nil && stat.IsDir() {
+					if ev.Op == fsnotify.Create {
+						err := w.addDir(watcher, ev.Name, w.schedule)
+						if err != nil {
+							return err
+						}
+					}
+					continue
 				}
 
-			// File renamed
-			case ev.Op == fsnotify.Rename:
-				// When files are renamed two events are fired, first a rename event with old file name
-				// and then a create event with the new file name.
-				// I don't want to delete the pod on renames and then create it again so I just remove
-				// the pod from the pods map and then run apply again when I receive the create event
-				// above, which should be a no-op.
-				delete(w.pods, ev.Name)
-
-			// File removed
-			case ev.Op == fsnotify.Remove:
-				// Get old pod from saved pods map
-				pod, ok := w.pods[ev.Name]
-				if !ok {
-					// We ignore it
+				// Ignore files that aren't pod definitions
+				if !w.isPodFile(ev.Name) {
 					continue
 				}
 
-				// Delete the pod from the pods map
-				delete(w.pods, ev.Name)
+				w.schedule(ev.Name)
 
-				// Send a delete event to channel
-				w.ch <- &PodFileEvent{
-					Type: TypeDelete,
-					Name: pod.Name,
+			// File renamed or removed
+			case ev.Op == fsnotify.Rename || ev.Op == fsnotify.Remove:
+				// Whether the file is gone is only decided once the quiet period is over.
+				// Editors often save files by renaming the old file away and writing a new
+				// one in its place, which should not delete the pod.
+				// If a directory was removed all files we know of in it are scheduled.
+				for _, p := range w.trackedPaths(ev.Name) {
+					w.schedule(p)
 				}
 			}
 
@@ -129,34 +173,326 @@ func (w *FileWatcher) Run(ctx context.Context) error {
 	}
 }
 
-func (w *FileWatcher) parseFile(p string) error {
+// addDir watches a directory and all of its subdirectories and calls handle
+// for all pod definition files in them.
+func (w *FileWatcher) addDir(watcher *fsnotify.Watcher, dir string, handle func(string)) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Watch directory
+		if d.IsDir() {
+			err := watcher.Add(p)
+			if err != nil {
+				return fmt.Errorf("could not watch directory '%s': %s", p, err)
+			}
+			return nil
+		}
+
+		// Ignore files that aren't pod definitions
+		if !w.isPodFile(p) {
+			return nil
+		}
+
+		handle(p)
+
+		return nil
+	})
+}
+
+// isPodFile checks if a file should be parsed as a pod definition.
+// Hidden files, such as editor swap and lock files, are always ignored.
+func (w *FileWatcher) isPodFile(p string) bool {
+	name := filepath.Base(p)
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range w.extensions {
+		if ext == strings.ToLower(e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// trackedPaths returns the path itself, if it's a pod definition file, and all
+// files we have read pods from in it, if it was a directory.
+func (w *FileWatcher) trackedPaths(p string) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	paths := []string{}
+	if w.isPodFile(p) {
+		paths = append(paths, p)
+	}
+	for fpath := range w.pods {
+		if strings.HasPrefix(fpath, p+string(filepath.Separator)) {
+			paths = append(paths, fpath)
+		}
+	}
+
+	return paths
+}
+
+// schedule marks a file to be processed once it hasn't had any events for the debounce period.
+func (w *FileWatcher) schedule(p string) {
+	w.pending[p] = time.Now().Add(w.debounce)
+}
+
+// nextFlush returns a channel that fires when the earliest pending file should be processed.
+func (w *FileWatcher) nextFlush() <-chan time.Time {
+	if len(w.pending) < 1 {
+		return nil
+	}
+
+	var earliest time.Time
+	for _, deadline := range w.pending {
+		if earliest.IsZero() || deadline.Before(earliest) {
+			earliest = deadline
+		}
+	}
+
+	return time.After(time.Until(earliest))
+}
+
+// flush processes all pending files whose quiet period is over.
+func (w *FileWatcher) flush() {
+	now := time.Now()
+
+	// Find files that are due and the latest deadline of those that aren't
+	due := []string{}
+	var latest time.Time
+	for p, deadline := range w.pending {
+		if deadline.After(now) {
+			if deadline.After(latest) {
+				latest = deadline
+			}
+			continue
+		}
+		due = append(due, p)
+	}
+	sort.Strings(due)
+
+	// Parse files that still exist first so pods that moved to another
+	// file are known before we look at the files that are gone
+	missing := []string{}
+	for _, p := range due {
+		if _, err := os.Stat(p); err != nil && os.IsNotExist(err) {
+			missing = append(missing, p)
+			continue
+		}
+
+		delete(w.pending, p)
+		w.parseFile(p)
+	}
+
+	for _, p := range missing {
+		// A file is renamed by removing the old path and creating the new one.
+		// If the new path is still pending we wait for it, so the pod is
+		// never deleted and created again.
+		if !latest.IsZero() {
+			w.pending[p] = latest
+			continue
+		}
+
+		delete(w.pending, p)
+		w.waitDependencies(p, nil)
+
+		// Remove the file from the pods map and send a delete event
+		// for every pod that was in it
+		w.mu.Lock()
+		pods := w.pods[p]
+		delete(w.pods, p)
+		delete(w.invalid, p)
+		w.mu.Unlock()
+
+		for _, pod := range pods {
+			w.sendDelete(pod.Name)
+		}
+	}
+}
+
+// parseFile parses a pod definition file and sends events for all changes in it.
+// If the file can't be read or parsed an invalid event is sent instead.
+func (w *FileWatcher) parseFile(p string) {
 	// Read file
 	buf, err := ioutil.ReadFile(p)
 	if err != nil {
-		return fmt.Errorf("could not read file '%s': %s", p, err)
+		w.sendInvalid(p, fmt.Errorf("could not read file '%s': %s", p, err))
+		return
+	}
+
+	// Render template
+	if w.renderer != nil {
+		var deps []template.Dependency
+		buf, deps, err = w.renderer.Render(p, buf)
+
+		// Also wait for changes when rendering failed, the data might show up later
+		w.waitDependencies(p, deps)
+
+		if err != nil {
+			w.sendInvalid(p, fmt.Errorf("could not render template '%s': %s", p, err))
+			return
+		}
 	}
 
 	// Parse file
-	pod := &entities.Pod{}
-	err = yaml.Unmarshal(buf, pod)
+	pods, err := podfile.Parse(buf)
 	if err != nil {
-		return fmt.Errorf("could not parse file '%s': %s", p, err)
+		w.sendInvalid(p, fmt.Errorf("could not parse file '%s': %s", p, err))
+		return
 	}
 
-	// Save pod in map.
-	// This is necessary so we can get the name of the pod when a file is deleted.
-	w.pods[p] = pod
+	// Save pods in map.
+	// This is necessary so we can get the names of the pods when a file is changed or deleted.
+	w.mu.Lock()
+	old := w.pods[p]
+	w.pods[p] = pods
+	delete(w.invalid, p)
+	w.mu.Unlock()
+
+	// Delete pods that were removed from the file
+	for _, oldPod := range old {
+		if !hasPod(pods, oldPod.Name) {
+			w.sendDelete(oldPod.Name)
+		}
+	}
 
 	// Send event to channel
+	for _, pod := range pods {
+		// Skip pods that are identical to the last ones sent
+		hash, err := pod.Hash()
+		w.mu.Lock()
+		if err == nil && w.sent[pod.Name] == hash {
+			w.mu.Unlock()
+			continue
+		}
+		w.sent[pod.Name] = hash
+		w.mu.Unlock()
+
+		w.ch <- &PodFileEvent{
+			Type: TypeApply,
+			Name: pod.Name,
+			Path: p,
+			Pod:  pod,
+		}
+	}
+}
+
+// waitDependencies replaces the dependency watch of a file and schedules the
+// file to be read again when any of the dependencies change.
+func (w *FileWatcher) waitDependencies(p string, deps []template.Dependency) {
+	if cancel, ok := w.depWaits[p]; ok {
+		cancel()
+		delete(w.depWaits, p)
+	}
+
+	if w.renderer == nil || len(deps) < 1 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(w.depCtx)
+	w.depWaits[p] = cancel
+
+	go func() {
+		err := w.renderer.Wait(ctx, deps)
+		if err != nil {
+			return
+		}
+
+		select {
+		case w.depChange <- p:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// sendInvalid records a file as invalid and sends an invalid event for it.
+func (w *FileWatcher) sendInvalid(p string, err error) {
+	w.mu.Lock()
+	w.invalid[p] = err
+	w.mu.Unlock()
+
 	w.ch <- &PodFileEvent{
-		Type: TypeApply,
-		Name: pod.Name,
-		Pod:  pod,
+		Type: TypeInvalid,
+		Path: p,
+		Err:  err,
 	}
+}
 
-	return nil
+// sendDelete sends a delete event for a pod unless it's still defined in another file.
+func (w *FileWatcher) sendDelete(name string) {
+	if _, ok := w.Pods()[name]; ok {
+		return
+	}
+	w.Forget(name)
+
+	w.ch <- &PodFileEvent{
+		Type: TypeDelete,
+		Name: name,
+	}
+}
+
+// Forget makes the watcher forget the last apply event it sent for a pod, so it's
+// sent again the next time the pod is read even if it hasn't changed.
+// It should be called when applying a pod fails.
+func (w *FileWatcher) Forget(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.sent, name)
+}
+
+func hasPod(pods []*entities.Pod, name string) bool {
+	for _, pod := range pods {
+		if pod.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 func (w *FileWatcher) PodFileEvents() <-chan *PodFileEvent {
 	return w.ch
 }
+
+// Synced returns a channel that is closed once all files in the watched
+// directory have been read for the first time.
+func (w *FileWatcher) Synced() <-chan struct{} {
+	return w.synced
+}
+
+// Pods returns all pods currently defined in the watched directory by name.
+// It is only complete after the channel returned by Synced has been closed.
+func (w *FileWatcher) Pods() map[string]*entities.Pod {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pods := map[string]*entities.Pod{}
+	for _, fpods := range w.pods {
+		for _, pod := range fpods {
+			pods[pod.Name] = pod
+		}
+	}
+
+	return pods
+}
+
+// InvalidFiles returns the files that could not be read or parsed, mapped to the error.
+// Pods keeps returning the pods of the last valid version of such a file, but a file
+// that has never been valid has no pods even though they might still be running.
+func (w *FileWatcher) InvalidFiles() map[string]error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	invalid := map[string]error{}
+	for p, err := range w.invalid {
+		invalid[p] = err
+	}
+
+	return invalid
+}